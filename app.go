@@ -0,0 +1,287 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// AppConfig configures an App.
+type AppConfig struct {
+	// Funcs are exported to Bash with ExportFunc
+	Funcs map[string]func([]string)
+
+	// Handlers are exported to Bash with ExportHandler
+	Handlers map[string]Handler
+
+	// Middleware is added to the Context with Use
+	Middleware []func(next Handler) Handler
+
+	// Wraps holds Go hooks to run around Bash functions, by name, which
+	// are added to the Context with WrapBashFunc
+	Wraps map[string]Wrap
+
+	// Specs holds the Spec for each of Funcs that has one, which is then
+	// exported with ExportFuncWithSpec instead. Specs for other names are
+	// given to DescribeFunc for Bash functions.
+	Specs map[string]Spec
+
+	// Complete, if set, adds completions computed in Go to those from
+	// Context.Complete. It is given the same words.
+	Complete func(args []string) []string
+
+	// Scripts are sourced in order before running main
+	Scripts []string
+
+	// Loader reads Scripts, as with Context.Source. If nil, Scripts are
+	// read from FS, or from disk if FS is also nil.
+	Loader func(string) ([]byte, error)
+
+	// FS is used to read Scripts when Loader is nil, for example an
+	// embed.FS holding the application's Bash sources.
+	FS fs.FS
+
+	// CopyEnv copies the environment of the Go process into Bash
+	CopyEnv bool
+
+	// Subcommands runs the Bash function or exported Go function named
+	// cmd-<command> for the arguments instead of main, with help generated
+	// from the commands defined. The command is chosen by a function added
+	// to the scripts, __basher_dispatch, in the same Bash that runs it.
+	Subcommands bool
+
+	// MultiCall names functions, from Bash or Funcs, that are run directly
+	// when the program is invoked under that name, as by a symlink made
+	// with InstallLinks. Other names run main or the subcommands as usual.
+	MultiCall []string
+
+	// Exec replaces the Go process with Bash to run the command, with the
+	// limitations described for Context.Exec. Main then only returns if
+	// Bash could not be started.
+	Exec bool
+
+	// PinExecutable calls Context.PinExecutable, so callbacks keep running
+	// this executable if it is replaced while Bash runs.
+	PinExecutable bool
+
+	// Debug sets Debug on the Context
+	Debug bool
+
+	// BashPath is the Bash to run. If empty, it is chosen by FindBash,
+	// preferring the embedded Bash.
+	BashPath string
+
+	// Args are the command line arguments including the program name,
+	// os.Args if nil.
+	Args []string
+
+	// Stdin, Stdout and Stderr are given to Bash, defaulting to those of
+	// the Go process.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// An App is a Bash application using exported Go functions, as set up by
+// Application, that reports failures to its caller instead of exiting.
+type App struct {
+	config AppConfig
+}
+
+// NewApp returns an App for config.
+func NewApp(config AppConfig) *App {
+	return &App{config: config}
+}
+
+// Main handles callbacks to exported Go functions, and otherwise sources the
+// scripts and runs the Bash function main, or the matching subcommand when
+// Subcommands is set, with the arguments after the program name. It returns
+// the status the program should exit with. Errors other than Bash exiting
+// with a non-zero status are returned along with a non-zero status.
+//
+// Some first arguments are handled by Main itself: --basher-version
+// describes the Bash in use and the embedded Bash, --basher-completion SHELL
+// writes a completion script with WriteCompletion, --install-links DIR runs
+// InstallLinks, and __complete prints the completions for the words after
+// it, one per line. None of these apply when invoked under a MultiCall name.
+func (a *App) Main(ctx context.Context) (int, error) {
+	config := a.config
+	args := config.Args
+	if args == nil {
+		args = os.Args
+	}
+
+	bash, err := NewContext(config.BashPath, config.Debug)
+	if err != nil {
+		return 1, err
+	}
+	if config.Stdin != nil {
+		bash.Stdin = config.Stdin
+	}
+	if config.Stdout != nil {
+		bash.Stdout = config.Stdout
+	}
+	if config.Stderr != nil {
+		bash.Stderr = config.Stderr
+	}
+	for name, fn := range config.Funcs {
+		if spec, ok := config.Specs[name]; ok {
+			bash.ExportFuncWithSpec(name, spec, fn)
+		} else {
+			bash.ExportFunc(name, fn)
+		}
+	}
+	for name, h := range config.Handlers {
+		bash.ExportHandler(name, h)
+	}
+	bash.Use(config.Middleware...)
+	for name, wrap := range config.Wraps {
+		bash.WrapBashFunc(name, wrap.Before, wrap.After)
+	}
+	for name, spec := range config.Specs {
+		if _, ok := config.Funcs[name]; !ok {
+			// for Handlers and Bash functions
+			bash.DescribeFunc(name, spec)
+		}
+	}
+	if handled, err := bash.HandleCallback(args); handled {
+		if errors.Is(err, ErrFunctionNotFound) {
+			return 127, err
+		}
+		if errors.Is(err, ErrInvalidCallback) {
+			return 2, err
+		}
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	// callbacks run Bash again with the Bash that called them, so it is
+	// only chosen now
+	if bash.BashPath == "" {
+		path, _, err := FindBash(Requirements{PreferEmbedded: true})
+		if err != nil {
+			return 1, err
+		}
+		bash.BashPath = path
+	}
+	name, multiCall := a.multiCallName(args)
+	if !multiCall && len(args) > 2 && args[1] == "--install-links" {
+		if err := a.InstallLinks(args[2]); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	if !multiCall && len(args) > 1 && args[1] == "--basher-version" {
+		writeBasherVersion(bash.Stdout, bash.BashPath)
+		return 0, nil
+	}
+	if !multiCall && len(args) > 2 && args[1] == "--basher-completion" {
+		if err := WriteCompletion(bash.Stdout, args[2], filepath.Base(args[0])); err != nil {
+			return 2, err
+		}
+		return 0, nil
+	}
+
+	if config.PinExecutable {
+		if err := bash.PinExecutable(); err != nil {
+			return 1, err
+		}
+	}
+
+	loader := config.Loader
+	if loader == nil && config.FS != nil {
+		loader = func(name string) ([]byte, error) {
+			return fs.ReadFile(config.FS, name)
+		}
+	}
+	for _, script := range config.Scripts {
+		if err := bash.Source(script, loader); err != nil {
+			return 1, err
+		}
+	}
+	if config.Subcommands {
+		var dispatch bytes.Buffer
+		writeDispatchFunc(&dispatch, bash.Specs())
+		bash.Source("<subcommands>", func(string) ([]byte, error) {
+			return dispatch.Bytes(), nil
+		})
+	}
+	if config.CopyEnv {
+		bash.CopyEnv()
+	}
+
+	var rest []string
+	if len(args) > 1 {
+		rest = args[1:]
+	}
+	if !multiCall && len(rest) > 0 && rest[0] == completeCommand {
+		return a.complete(ctx, bash, rest[1:])
+	}
+	var status int
+	if multiCall {
+		status, err = a.run(ctx, bash, name, rest)
+	} else if config.Subcommands {
+		status, err = a.run(ctx, bash, "__basher_dispatch", append([]string{filepath.Base(args[0])}, rest...))
+	} else {
+		status, err = a.run(ctx, bash, "main", rest)
+	}
+	if err != nil {
+		// the string message for ExitError shouldn't be reported
+		// as it is just `exit status $CODE`, which is redundant
+		// when that code can just be used to exit the program
+		if _, ok := err.(*exec.ExitError); ok && strings.HasPrefix(err.Error(), "exit status ") {
+			return status, nil
+		}
+		if status == 0 {
+			status = 1
+		}
+		return status, err
+	}
+	return status, nil
+}
+
+// run runs command in Bash, replacing the process if Exec is set.
+func (a *App) run(ctx context.Context, bash *Context, command string, args []string) (int, error) {
+	if a.config.Exec {
+		return 1, bash.Exec(command, args)
+	}
+	return bash.RunContext(ctx, command, args)
+}
+
+// complete prints the completions for args on Stdout.
+func (a *App) complete(ctx context.Context, bash *Context, args []string) (int, error) {
+	candidates, err := bash.Complete(ctx, args)
+	if err != nil {
+		return 1, err
+	}
+	if a.config.Complete != nil {
+		candidates = append(candidates, a.config.Complete(args)...)
+	}
+	for _, candidate := range candidates {
+		fmt.Fprintln(bash.Stdout, candidate)
+	}
+	return 0, nil
+}
+
+// writeBasherVersion writes the output of --basher-version for an
+// application running the Bash at bashPath.
+func writeBasherVersion(w io.Writer, bashPath string) {
+	if version, err := bashVersion(bashPath); err == nil {
+		fmt.Fprintf(w, "bash: %s (%s)\n", bashPath, version)
+	} else {
+		fmt.Fprintf(w, "bash: %s (unknown version: %v)\n", bashPath, err)
+	}
+	if info, err := EmbeddedBashInfo(); err == nil {
+		fmt.Fprintf(w, "embedded: %s\n", info)
+	} else {
+		fmt.Fprintf(w, "embedded: none (%v)\n", err)
+	}
+}
@@ -0,0 +1,168 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAppMain(t *testing.T) {
+	var stdout bytes.Buffer
+	app := NewApp(AppConfig{
+		Scripts:  []string{"app.sh"},
+		Loader:   testLoader,
+		BashPath: bashpath,
+		Args:     []string{"app", "world"},
+		Stdout:   &stdout,
+	})
+	status, err := app.Main(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if stdout.String() != "hello world\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestAppMainExitStatus(t *testing.T) {
+	var stderr bytes.Buffer
+	app := NewApp(AppConfig{
+		Scripts:  []string{"app.sh"},
+		Loader:   testLoader,
+		BashPath: bashpath,
+		Args:     []string{"app", "fail"},
+		Stderr:   &stderr,
+	})
+	status, err := app.Main(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 4 {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if stderr.String() != "failing\n" {
+		t.Fatalf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestAppMainLoaderError(t *testing.T) {
+	app := NewApp(AppConfig{
+		Scripts:  []string{"missing.sh"},
+		Loader:   testLoader,
+		BashPath: bashpath,
+		Args:     []string{"app"},
+	})
+	status, err := app.Main(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing.sh") {
+		t.Fatalf("expected loader error, got %v", err)
+	}
+	if status == 0 {
+		t.Fatal("expected non-zero status")
+	}
+}
+
+func TestAppMainFS(t *testing.T) {
+	var stdout bytes.Buffer
+	app := NewApp(AppConfig{
+		Scripts:  []string{"app.sh"},
+		FS:       fstest.MapFS{"app.sh": {Data: []byte(testScripts["app.sh"])}},
+		BashPath: bashpath,
+		Args:     []string{"app", "fs"},
+		Stdout:   &stdout,
+	})
+	if _, err := app.Main(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "hello fs\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestAppMainCallback(t *testing.T) {
+	t.Setenv(callbackVar, "nonce")
+	var got []string
+	app := NewApp(AppConfig{
+		Funcs: map[string]func([]string){
+			"reverse": func(args []string) { got = args },
+		},
+		BashPath: bashpath,
+		Args:     []string{"app", "nonce", "reverse", "a", "b"},
+	})
+	status, err := app.Main(context.Background())
+	if err != nil || status != 0 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Fatalf("unexpected callback args: %q", got)
+	}
+}
+
+func TestAppMainCallbackNotFound(t *testing.T) {
+	t.Setenv(callbackVar, "nonce")
+	app := NewApp(AppConfig{
+		BashPath: bashpath,
+		Args:     []string{"app", "nonce", "missing"},
+	})
+	status, err := app.Main(context.Background())
+	if !errors.Is(err, ErrFunctionNotFound) || status != 127 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+}
+
+func TestAppMainCallbackMalformed(t *testing.T) {
+	t.Setenv(callbackVar, "nonce")
+	app := NewApp(AppConfig{
+		Scripts:  []string{"app.sh"},
+		Loader:   testLoader,
+		BashPath: bashpath,
+		Args:     []string{"app", "nonce"},
+	})
+	status, err := app.Main(context.Background())
+	if !errors.Is(err, ErrInvalidCallback) || status != 2 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+}
+
+func TestAppMainWraps(t *testing.T) {
+	var got []string
+	app := NewApp(AppConfig{
+		Wraps: map[string]Wrap{
+			"build": {After: func(args []string, status int) {
+				got = append(args, strconv.Itoa(status))
+			}},
+		},
+		BashPath: bashpath,
+		Args:     []string{"app", "nonce", "__basher_after_build", "3", "fast"},
+	})
+	t.Setenv(callbackVar, "nonce")
+	status, err := app.Main(context.Background())
+	if err != nil || status != 0 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	if strings.Join(got, ",") != "fast,3" {
+		t.Fatalf("unexpected hook args: %q", got)
+	}
+}
+
+func TestAppMainBasherVersion(t *testing.T) {
+	var stdout bytes.Buffer
+	app := NewApp(AppConfig{
+		BashPath: bashpath,
+		Args:     []string{"app", "--basher-version"},
+		Stdout:   &stdout,
+	})
+	status, err := app.Main(context.Background())
+	if err != nil || status != 0 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	if !strings.HasPrefix(stdout.String(), "bash: "+bashpath+" (") {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
@@ -0,0 +1,124 @@
+//go:build !basher_nobash
+// +build !basher_nobash
+
+package basher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// assetInfo describes the embedded "bash" asset.
+type assetInfo struct{}
+
+func (assetInfo) Name() string       { return "bash" }
+func (assetInfo) Size() int64        { return bashSize }
+func (assetInfo) Mode() os.FileMode  { return 0755 }
+func (assetInfo) ModTime() time.Time { return bashModTime }
+func (assetInfo) IsDir() bool        { return false }
+func (assetInfo) Sys() interface{}   { return nil }
+
+// openAsset returns a reader that decompresses the named asset as it is
+// read, so the whole of it never needs to be held in memory.
+func openAsset(name string) (io.ReadCloser, os.FileInfo, error) {
+	if name != "bash" {
+		return nil, nil, fmt.Errorf("Asset %s not found", name)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(bashGzip))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	return gz, assetInfo{}, nil
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func Asset(name string) ([]byte, error) {
+	r, _, err := openAsset(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	return data, nil
+}
+
+// MustAsset is like Asset but panics when Asset would return an error.
+func MustAsset(name string) []byte {
+	a, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+	return a
+}
+
+// AssetInfo loads and returns the asset info for the given name.
+// It returns an error if the asset could not be found.
+func AssetInfo(name string) (os.FileInfo, error) {
+	if name != "bash" {
+		return nil, fmt.Errorf("AssetInfo %s not found", name)
+	}
+	return assetInfo{}, nil
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() []string {
+	return []string{"bash"}
+}
+
+// AssetDir returns the file names below a certain directory of assets.
+// The only asset is "bash", so AssetDir("") returns []string{"bash"} and
+// any other name returns an error.
+func AssetDir(name string) ([]string, error) {
+	if name != "" {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	return AssetNames(), nil
+}
+
+// RestoreAsset restores an asset under the given directory
+func RestoreAsset(dir, name string) error {
+	r, info, err := openAsset(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+// RestoreAssets restores an asset under the given directory recursively
+func RestoreAssets(dir, name string) error {
+	if name == "" {
+		for _, child := range AssetNames() {
+			if err := RestoreAsset(dir, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return RestoreAsset(dir, name)
+}
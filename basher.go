@@ -3,7 +3,10 @@ package basher
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,13 +14,13 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/kardianos/osext"
-	"github.com/mitchellh/go-homedir"
 )
 
 // staleBashTmpAge is the minimum age before a leftover "bash.tmp.*" file is
@@ -26,6 +29,10 @@ import (
 // milliseconds, so anything an hour old cannot belong to a live extractor.
 const staleBashTmpAge = time.Hour
 
+// ErrFunctionNotFound is returned by Run and RunContext when the command to
+// run is not defined as a function, builtin or executable in the Context.
+var ErrFunctionNotFound = errors.New("basher: function not found")
+
 func exitStatus(err error) (int, error) {
 	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
@@ -47,10 +54,12 @@ func exitStatus(err error) (int, error) {
 
 // Application sets up a common entrypoint for a Bash application that
 // uses exported Go functions. It uses the DEBUG environment variable
-// to set debug on the Context, and SHELL for the Bash binary if it
-// includes the string "bash". You can pass a loader function to use
-// for the sourced files, and a boolean for whether or not the
-// environment should be copied into the Context process.
+// to set debug on the Context. The Bash binary is chosen by FindBash,
+// preferring the embedded Bash: BASHER_BASH overrides it, and SHELL (if it
+// includes the string "bash") or PATH are used when the embedded Bash is
+// unavailable, such as when built with the basher_nobash tag. You can pass
+// a loader function to use for the sourced files, and a boolean for whether
+// or not the environment should be copied into the Context process.
 func Application(
 	funcs map[string]func([]string),
 	scripts []string,
@@ -70,17 +79,13 @@ func ApplicationContext(
 	loader func(string) ([]byte, error),
 	copyEnv bool) {
 
-	bashDir, err := homedir.Expand("~/.basher")
-	if err != nil {
-		log.Fatal(err, "1")
-	}
-
-	if err := restoreBashAtomically(bashDir); err != nil {
-		log.Fatal(err, "1")
-	}
-	bashPath := filepath.Join(bashDir, "bash")
-
-	ApplicationWithPathContext(ctx, funcs, scripts, loader, copyEnv, bashPath)
+	exitApp(ctx, AppConfig{
+		Funcs:   funcs,
+		Scripts: scripts,
+		Loader:  loader,
+		CopyEnv: copyEnv,
+		Debug:   os.Getenv("DEBUG") != "",
+	})
 }
 
 // ApplicationWithPath functions as Application does while also
@@ -98,6 +103,8 @@ func ApplicationWithPath(
 // ApplicationWithPathContext is like ApplicationWithPath but accepts a
 // context.Context that is forwarded to the underlying Bash invocation.
 // Cancelling ctx kills the Bash process via exec.CommandContext (SIGKILL).
+// If the first argument is --basher-version, the Bash in use and the
+// embedded Bash are described on STDOUT instead of running main.
 func ApplicationWithPathContext(
 	ctx context.Context,
 	funcs map[string]func([]string),
@@ -106,46 +113,37 @@ func ApplicationWithPathContext(
 	copyEnv bool,
 	bashPath string) {
 
-	bash, err := NewContext(bashPath, os.Getenv("DEBUG") != "")
-	if err != nil {
-		log.Fatal(err)
-	}
-	for name, fn := range funcs {
-		bash.ExportFunc(name, fn)
-	}
-	if bash.HandleFuncs(os.Args) {
-		os.Exit(0)
-	}
+	exitApp(ctx, AppConfig{
+		Funcs:    funcs,
+		Scripts:  scripts,
+		Loader:   loader,
+		CopyEnv:  copyEnv,
+		BashPath: bashPath,
+		Debug:    os.Getenv("DEBUG") != "",
+	})
+}
 
-	for _, script := range scripts {
-		if err := bash.Source(script, loader); err != nil {
-			log.Fatal(err)
-		}
-	}
-	if copyEnv {
-		bash.CopyEnv()
-	}
-	status, err := bash.RunContext(ctx, "main", os.Args[1:])
+// exitApp runs the App for config and exits the process with its status.
+func exitApp(ctx context.Context, config AppConfig) {
+	status, err := NewApp(config).Main(ctx)
 	if err != nil {
-		// the string message for ExitError shouldn't be logged
-		// as it is just `exit status $CODE`, which is redundant
-		// when that code can just be used to exit the program
-		if _, ok := err.(*exec.ExitError); ok && strings.HasPrefix(err.Error(), "exit status ") {
-			os.Exit(status)
-		} else {
-			log.Fatal(err)
-		}
+		log.Print(err)
 	}
 	os.Exit(status)
 }
 
+// bashStampName is the sidecar file recording the SHA-256 of an extracted
+// bash, along with the size and mtime the file had when it was verified.
+const bashStampName = "bash.stamp"
+
 // restoreBashAtomically extracts the embedded "bash" asset to dir/bash so
 // that concurrent first-run invocations cannot observe a partial file. It
 // writes the asset to a unique temp file in the same directory and renames it
 // into place; the rename is atomic on POSIX filesystems, so any visible
-// dir/bash is always byte-complete. If dir/bash already exists, no work is
-// done. After a successful extraction, stale "bash.tmp.*" files left behind
-// by previously crashed extractions are best-effort swept.
+// dir/bash is always byte-complete. If dir/bash already exists and matches the
+// embedded asset, no work is done; a truncated, corrupted or outdated file is
+// replaced. After a successful extraction, stale "bash.tmp.*" files left
+// behind by previously crashed extractions are best-effort swept.
 func restoreBashAtomically(dir string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
@@ -153,23 +151,49 @@ func restoreBashAtomically(dir string) error {
 
 	finalPath := filepath.Join(dir, "bash")
 	if _, err := os.Stat(finalPath); err == nil {
-		return nil
+		if verifyBash(dir) {
+			return nil
+		}
 	} else if !os.IsNotExist(err) {
 		return err
 	}
 
-	data, err := Asset("bash")
+	asset, info, err := openAsset("bash")
 	if err != nil {
 		return err
 	}
-	info, err := AssetInfo("bash")
+	defer asset.Close()
+
+	// the asset is hashed as it is decompressed, so it is never held in
+	// memory as a whole
+	h := sha256.New()
+	tmpName, err := writeTempFile(dir, io.TeeReader(asset, h), info.Mode())
 	if err != nil {
 		return err
 	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != bashSHA256 {
+		os.Remove(tmpName)
+		return errors.New("basher: embedded bash does not match its checksum")
+	}
+	if err := os.Rename(tmpName, finalPath); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if extracted, err := os.Stat(finalPath); err == nil {
+		writeBashStamp(dir, extracted)
+	}
+	sweepStaleBashTmp(dir, filepath.Base(tmpName), staleBashTmpAge)
+	return nil
+}
 
+// writeTempFile copies r to a new "bash.tmp.*" file in dir with the given
+// mode and returns its path. The file is synced to disk before returning so
+// it can be safely renamed into place.
+func writeTempFile(dir string, r io.Reader, mode os.FileMode) (string, error) {
 	tmp, err := os.CreateTemp(dir, "bash.tmp.*")
 	if err != nil {
-		return err
+		return "", err
 	}
 	tmpName := tmp.Name()
 	committed := false
@@ -179,27 +203,71 @@ func restoreBashAtomically(dir string) error {
 		}
 	}()
 
-	if _, err := tmp.Write(data); err != nil {
+	if _, err := io.Copy(tmp, r); err != nil {
 		tmp.Close()
-		return err
+		return "", err
 	}
 	if err := tmp.Sync(); err != nil {
 		tmp.Close()
-		return err
+		return "", err
 	}
 	if err := tmp.Close(); err != nil {
-		return err
-	}
-	if err := os.Chmod(tmpName, info.Mode()); err != nil {
-		return err
+		return "", err
 	}
-	if err := os.Rename(tmpName, finalPath); err != nil {
-		return err
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return "", err
 	}
 	committed = true
+	return tmpName, nil
+}
 
-	sweepStaleBashTmp(dir, filepath.Base(tmpName), staleBashTmpAge)
-	return nil
+// verifyBash reports whether dir/bash matches the embedded asset. When the
+// stamp file agrees with the file's current size and mtime the check is just
+// a stat; otherwise the file is hashed and, if it matches, the stamp is
+// rewritten so the next check is cheap again.
+func verifyBash(dir string) bool {
+	bashPath := filepath.Join(dir, "bash")
+	info, err := os.Stat(bashPath)
+	if err != nil {
+		return false
+	}
+	stamp, err := os.ReadFile(filepath.Join(dir, bashStampName))
+	if err == nil && string(stamp) == bashStamp(info) {
+		return true
+	}
+
+	f, err := os.Open(bashPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != bashSHA256 {
+		return false
+	}
+	writeBashStamp(dir, info)
+	return true
+}
+
+// bashStamp returns the stamp file contents for an extracted bash with the
+// given file info.
+func bashStamp(info os.FileInfo) string {
+	return fmt.Sprintf("%s %d %d\n", bashSHA256, info.Size(), info.ModTime().UnixNano())
+}
+
+// writeBashStamp records that the bash described by info has been verified.
+// It is best-effort: without a stamp the next check simply hashes the file.
+func writeBashStamp(dir string, info os.FileInfo) {
+	tmpName, err := writeTempFile(dir, strings.NewReader(bashStamp(info)), 0644)
+	if err != nil {
+		return
+	}
+	if err := os.Rename(tmpName, filepath.Join(dir, bashStampName)); err != nil {
+		os.Remove(tmpName)
+	}
 }
 
 // sweepStaleBashTmp removes "bash.tmp.*" entries in dir whose mtime is older
@@ -254,9 +322,50 @@ type Context struct {
 	// The io.Writer given to Bash for STDERR
 	Stderr io.Writer
 
+	// OnEvent, if set, is called with each Event sent by basher_emit during
+	// a run. It is called from a single goroutine while Bash is running, in
+	// the order the events were emitted, and must not call back into the
+	// Context. To receive events on a channel, send to it from OnEvent.
+	// Once Bash exits, the events it left in the pipe are still delivered,
+	// but events from background processes that outlive it may be dropped.
+	OnEvent func(Event)
+
+	// MaxDepth limits how deeply callbacks can run Bash again through
+	// Callback.Context, as a guard against runaway recursion. If zero,
+	// DefaultMaxDepth is used.
+	MaxDepth int
+
 	vars    []string
+	streams []stream
 	scripts [][]byte
-	funcs   map[string]func([]string)
+	sources []string
+	funcs   map[string]Handler
+	specs   map[string]Spec
+	nonce   string
+
+	middleware []func(Handler) Handler
+	wraps      []bashWrap
+
+	// exePath is the SelfPath that exeID identifies, for callbacks to
+	// check they run the same executable
+	exePath  string
+	exeID    string
+	selfFile *os.File
+
+	// depth is how many callbacks deep this Context is, and inherited the
+	// envfile of the run it was called back from. See Callback.Context.
+	depth     int
+	inherited string
+}
+
+// FunctionInfo describes a Bash function defined in a Context. Source is the
+// path given to Source for the script defining the function and Line is the
+// line within that script. Functions produced by ExportFunc or inherited from
+// the environment have an empty Source and a Line of zero.
+type FunctionInfo struct {
+	Name   string
+	Source string
+	Line   int
 }
 
 // Creates and initializes a new Context that will use the given Bash executable.
@@ -266,6 +375,11 @@ func NewContext(bashpath string, debug bool) (*Context, error) {
 	if err != nil {
 		return nil, err
 	}
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	exeID, _ := selfID()
 	return &Context{
 		Debug:    debug,
 		BashPath: bashpath,
@@ -274,8 +388,13 @@ func NewContext(bashpath string, debug bool) (*Context, error) {
 		Stdout:   os.Stdout,
 		Stderr:   os.Stderr,
 		scripts:  make([][]byte, 0),
+		sources:  make([]string, 0),
 		vars:     make([]string, 0),
-		funcs:    make(map[string]func([]string)),
+		funcs:    make(map[string]Handler),
+		specs:    make(map[string]Spec),
+		nonce:    nonce,
+		exePath:  executable,
+		exeID:    exeID,
 	}, nil
 }
 
@@ -301,6 +420,7 @@ func (c *Context) Source(filepath string, loader func(string) ([]byte, error)) e
 	c.Lock()
 	defer c.Unlock()
 	c.scripts = append(c.scripts, data)
+	c.sources = append(c.sources, filepath)
 	return nil
 }
 
@@ -314,29 +434,28 @@ func (c *Context) Export(name string, value string) {
 // Registers a function with the Context that will produce a Bash function in the environment
 // that calls back into your executable triggering the function defined as fn.
 func (c *Context) ExportFunc(name string, fn func([]string)) {
-	c.Lock()
-	defer c.Unlock()
-	c.funcs[name] = fn
+	c.ExportHandler(name, argsHandler(fn))
 }
 
 // Expects your os.Args to parse and handle any callbacks to Go functions registered with
 // ExportFunc. You normally call this at the beginning of your program. If a registered
 // function is found and handled, HandleFuncs will exit with the appropriate exit code for you.
+// A callback that can't be handled is reported on stderr and exits, with status 127 if
+// the function is not registered, as Bash does for an unknown command, and 2 if it is
+// malformed. See HandleCallback.
 func (c *Context) HandleFuncs(args []string) bool {
-	for i, arg := range args {
-		if arg == ":::" && len(args) > i+1 {
-			c.Lock()
-			defer c.Unlock()
-			for cmd := range c.funcs {
-				if cmd == args[i+1] {
-					c.funcs[cmd](args[i+2:])
-					return true
-				}
-			}
-			return false
+	handled, err := c.HandleCallback(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, ErrFunctionNotFound) {
+			os.Exit(127)
+		}
+		if errors.Is(err, ErrInvalidCallback) {
+			os.Exit(2)
 		}
+		os.Exit(1)
 	}
-	return false
+	return handled
 }
 
 func (c *Context) buildEnvfile() (string, error) {
@@ -371,7 +490,8 @@ func (c *Context) buildEnvfile() (string, error) {
 // captured and surfaced from the final Flush, rather than being silently
 // dropped by individual Write calls.
 func (c *Context) writeEnvfile(w io.Writer) error {
-	bw := bufio.NewWriter(w)
+	lines := &lineCounter{w: w}
+	bw := bufio.NewWriter(lines)
 	// variables
 	fmt.Fprint(bw, "unset BASH_ENV\n") // unset for future calls to bash
 	fmt.Fprintf(bw, "export SELF=%s\n", os.Args[0])
@@ -381,6 +501,10 @@ func (c *Context) writeEnvfile(w io.Writer) error {
 		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
 			continue
 		}
+		if pair[0] == eventFDVar || pair[0] == callbackVar {
+			// a descriptor inherited from an outer run is meaningless here
+			continue
+		}
 
 		if isBashFunc(pair[0], pair[1]) {
 			bashFuncName := strings.TrimPrefix(pair[0], "BASH_FUNC_")
@@ -394,17 +518,91 @@ func (c *Context) writeEnvfile(w io.Writer) error {
 			strings.Replace(kvp, "'", "\\'", -1), "=", "=$'", 1))
 	}
 	// functions
-	for cmd := range c.funcs {
-		fmt.Fprintf(bw, "%s() { $SELF_EXECUTABLE ::: %s \"$@\"; }\n", cmd, cmd)
+	c.writeFuncs(bw)
+	bw.WriteString(emitFunc)
+	bw.WriteString(locateFunc)
+	// scripts, preceded by where they start for Caller
+	if err := bw.Flush(); err != nil {
+		return err
 	}
-	// scripts
+	c.writeScriptTable(bw, lines.lines+1)
 	for _, data := range c.scripts {
 		bw.Write(data)
 		bw.WriteByte('\n')
 	}
+	// wrappers for WrapBashFunc
+	bw.WriteString(c.wrapCode())
 	return bw.Flush()
 }
 
+// probe sources envfile in a throwaway Bash, discarding any output produced
+// by the scripts themselves, then runs script and returns its stdout.
+func (c *Context) probe(ctx context.Context, envfile string, script string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.BashPath, "-c",
+		"source "+shellQuote(envfile)+" >/dev/null 2>&1 </dev/null; "+script)
+	cmd.Env = []string{}
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Functions sources the Context in a throwaway Bash and returns every function
+// it defines, including those produced by ExportFunc. Top-level code in the
+// sourced scripts is run as part of this, with its output discarded.
+func (c *Context) Functions(ctx context.Context) ([]FunctionInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	// wrappers would hide where the functions they wrap are defined
+	wraps := c.wraps
+	c.wraps = nil
+	envfile, err := c.buildEnvfile()
+	c.wraps = wraps
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(envfile)
+
+	// functions are located with the same table as callers
+	out, err := c.probe(ctx, envfile, `shopt -s extdebug
+for f in $(compgen -A function); do
+	read -r _ line file < <(declare -F "$f")
+	if [[ $file == "$__basher_envfile" ]]; then
+		__basher_locate "$file" "$line"
+	else
+		__basher_source= __basher_line=
+	fi
+	printf '%s %s %s\n' "$f" "${__basher_line:-0}" "$__basher_source"
+done`)
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := make([]FunctionInfo, 0)
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		// each line is "name line source", with an empty source for
+		// functions not defined by a script
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "__basher_") || fields[0] == "basher_emit" || fields[0] == "basher_help" {
+			// generated by go-basher
+			continue
+		}
+		lineno, _ := strconv.Atoi(fields[1])
+		funcs = append(funcs, FunctionInfo{Name: fields[0], Source: fields[2], Line: lineno})
+	}
+	return funcs, nil
+}
+
+// shellQuote quotes s as a single word for Bash.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\\''", -1) + "'"
+}
+
 func isBashFunc(key string, value string) bool {
 	return strings.HasPrefix(key, "BASH_FUNC_") && strings.HasPrefix(value, "()")
 }
@@ -425,16 +623,125 @@ func (c *Context) Run(command string, args []string) (int, error) {
 func (c *Context) RunContext(ctx context.Context, command string, args []string) (int, error) {
 	c.Lock()
 	defer c.Unlock()
-	envfile, err := c.buildEnvfile()
+	return c.run(ctx, &invocation{
+		command: command,
+		args:    args,
+		stdin:   c.Stdin,
+		stdout:  c.Stdout,
+		stderr:  c.Stderr,
+	})
+}
+
+// An invocation describes a single run of Bash by a Context.
+type invocation struct {
+	command string
+	args    []string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+
+	// files are passed to Bash as descriptors 3 and up, in order. They are
+	// closed by run once Bash has been started.
+	files []*os.File
+
+	// private lists descriptors from files that are closed for the
+	// sourced scripts and the command itself.
+	private []int
+
+	// epilogue is Bash code run in the same shell after the command. The
+	// exit status of the command is preserved.
+	epilogue string
+
+	// runID identifies the run to scripts and callbacks
+	runID string
+
+	// depth is the depth of the Context making the run
+	depth int
+
+	// notFound, if set, is the descriptor Bash writes to before exiting
+	// with status 127 when the command is not defined
+	notFound int
+}
+
+// addFile passes f to Bash and returns the descriptor number it will have.
+func (inv *invocation) addFile(f *os.File, private bool) int {
+	inv.files = append(inv.files, f)
+	fd := 2 + len(inv.files)
+	if private {
+		inv.private = append(inv.private, fd)
+	}
+	return fd
+}
+
+// script returns the -c argument that sources envfile and runs the command.
+func (inv *invocation) script(envfile string) string {
+	closes := ""
+	for _, fd := range inv.private {
+		closes += " " + strconv.Itoa(fd) + ">&-"
+	}
+	script := runIDVar + "=" + inv.runID + " __basher_depth=" + strconv.Itoa(inv.depth) + "; source " + shellQuote(envfile) + closes + "; "
+	if inv.notFound != 0 {
+		script += fmt.Sprintf("type -t -- %s >/dev/null || { echo >&%d; exit 127; }; ", shellQuote(inv.command), inv.notFound)
+	}
+	script += inv.command
+	for _, arg := range inv.args {
+		script += " " + shellQuote(arg)
+	}
+	script += closes
+	if inv.epilogue != "" {
+		script += "; __basher_status=$?; " + inv.epilogue + "; exit $__basher_status"
+	}
+	return script
+}
+
+// run starts Bash for inv and waits for it to exit. The caller must hold the
+// Context lock.
+func (c *Context) run(ctx context.Context, inv *invocation) (int, error) {
+	defer func() {
+		for _, f := range inv.files {
+			f.Close()
+		}
+	}()
+	if err := c.checkDepth(); err != nil {
+		return 0, err
+	}
+	runID, err := newNonce()
 	if err != nil {
 		return 0, err
 	}
-	if !c.Debug {
-		defer os.Remove(envfile)
+	inv.runID = runID
+	inv.depth = c.depth
+	notFound, notFoundW, err := os.Pipe()
+	if err != nil {
+		return 0, err
 	}
-	argstring := ""
-	for _, arg := range args {
-		argstring = argstring + " '" + strings.Replace(arg, "'", "'\\''", -1) + "'"
+	defer notFound.Close()
+	inv.notFound = inv.addFile(notFoundW, true)
+	env := make([]string, 0)
+	if c.OnEvent != nil {
+		fdvar, wait, err := c.wireEvents(inv)
+		if err != nil {
+			return 0, err
+		}
+		defer wait()
+		env = append(env, fdvar)
+	}
+	streamVars, finishStreams, err := c.wireStreams(inv)
+	if err != nil {
+		return 0, err
+	}
+	defer finishStreams()
+	env = append(env, streamVars...)
+
+	envfile := c.inherited
+	if envfile == "" {
+		envfile, err = c.buildEnvfile()
+		if err != nil {
+			return 0, err
+		}
+		if !c.Debug {
+			defer os.Remove(envfile)
+		}
 	}
 
 	signals := make(chan os.Signal, 1)
@@ -442,14 +749,18 @@ func (c *Context) RunContext(ctx context.Context, command string, args []string)
 	signal.Ignore(syscall.SIGURG)
 	defer signal.Stop(signals)
 
-	cmd := exec.CommandContext(ctx, c.BashPath, "-c", "source '"+envfile+"'; "+command+argstring)
-	cmd.Env = []string{"BASH_ENV=" + envfile}
-	cmd.Stdin = c.Stdin
-	cmd.Stdout = c.Stdout
-	cmd.Stderr = c.Stderr
+	cmd := exec.CommandContext(ctx, c.BashPath, "-c", inv.script(envfile))
+	cmd.Env = append(env, "BASH_ENV="+envfile)
+	cmd.Stdin = inv.stdin
+	cmd.Stdout = inv.stdout
+	cmd.Stderr = inv.stderr
+	cmd.ExtraFiles = inv.files
 	if err := cmd.Start(); err != nil {
 		return 0, err
 	}
+	for _, f := range inv.files {
+		f.Close()
+	}
 
 	done := make(chan struct{})
 	defer close(done)
@@ -466,5 +777,22 @@ func (c *Context) RunContext(ctx context.Context, command string, args []string)
 		}
 	}()
 
-	return exitStatus(cmd.Wait())
+	status, err := exitStatus(cmd.Wait())
+	if streamErr := finishStreams(); err == nil && streamErr != nil {
+		return status, streamErr
+	}
+	if status == 127 && ctx.Err() == nil && reportedNotFound(notFound) {
+		return status, fmt.Errorf("%w: %s", ErrFunctionNotFound, inv.command)
+	}
+	return status, err
+}
+
+// reportedNotFound reports whether Bash wrote to the notFound descriptor of
+// an invocation, meaning the command was not defined rather than returning
+// 127 itself. A process started in the background by a sourced script may
+// still hold the descriptor open, so the read gives up after a short wait.
+func reportedNotFound(r *os.File) bool {
+	r.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	n, _ := r.Read(make([]byte, 1))
+	return n > 0
 }
@@ -3,13 +3,12 @@ package basher
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -24,10 +23,111 @@ var testScripts = map[string]string{
 	"foobar.sh":    `main() { echo $FOOBAR; }`,
 	"sleep.sh":     `main() { sleep 0.2; }`,
 	"longsleep.sh": `main() { sleep 2; }`,
+	"helpers.sh":   "echo noise\nhelper() {\n  true\n}\n\nother() { true; }",
+	"exit127.sh":   `main() { return 127; }`,
+	"counted.sh":   `echo x >>"$COUNT_FILE"; main() { true; }`,
+	"app.sh": `
+main() {
+	case "$1" in
+	fail) echo "failing" >&2; return 4 ;;
+	*) echo "hello $*" ;;
+	esac
+}
+`,
+	"call.sh": `
+greet() { printf "  hello %s\n\n" "$1"; }
+info() { printf '{"name":"%s","count":%d}' "$1" "$2"; }
+fail() { echo "went wrong" >&2; return 3; }
+`,
+	"first.sh": `
+first() { :; }
+`,
+	"second.sh": `
+helper() {
+	myfunc a
+}
+main() {
+	cd /
+	echo "run=$BASHER_RUN_ID"
+	helper
+}
+`,
+	"capture.sh": `
+main() {
+	VERSION="1.2.3"
+	MESSAGE=$'multi\nline "quoted" $x \\ \001'
+	LIST=(one "two words" $'x\ny')
+	LIST[7]=z
+	declare -gA MAP=([k]=v ["sp ace"]='q"' [$'n\nl']=1)
+	declare -g EMPTY
+	echo "output"
+	return 4
+}
+`,
+	"events.sh": `
+main() {
+	basher_emit progress step=build "message=hello world" $'multi=a\nb' flag
+	echo "stdout is untouched"
+	basher_emit done
+}
+flood() {
+	local i
+	for ((i = 0; i < $1; i++)); do
+		basher_emit tick seq=$i
+	done
+}
+nested() {
+	bash -c 'basher_emit child pid=$$'
+}
+background() {
+	sleep 3 >/dev/null 2>&1 &
+	basher_emit started
+}
+`,
+	"multi.sh": `
+greet() { echo "hello $*"; }
+main() { echo "main $*"; }
+showpid() { echo $$; }
+flaky() {
+	local n=$(($(cat "$COUNTER" 2>/dev/null || echo 0) + 1))
+	echo "$n" >"$COUNTER"
+	echo "attempt $n $*"
+	((n >= 2))
+}
+retrydemo() { retry flaky "$@"; }
+upgrade() { cp "$UPGRADE" "$UPGRADE.new" && mv "$UPGRADE.new" "$UPGRADE" && shout "$@"; }
+`,
+	"streams.sh": `
+upper() { tr a-z A-Z < "$INPUT" > "$OUTPUT"; }
+ignore() { true; }
+both() { cat "$INPUT" > "$OUTPUT"; echo extra > "$OUTPUT"; }
+take() { read -r -N "$1" data < "$INPUT"; printf %s "$data"; }
+count() { wc -c < "$INPUT"; }
+`,
+	"cli.sh": `
+cmd-deploy() { echo "deploying $*"; }
+cmd-db-migrate() { echo "migrating $*"; }
+cmd-db-seed() { return 3; }
+helper() { :; }
+`,
+	"keyword.sh": `
+function cmd-status {
+	echo ok
+}
+  cmd-db-reset () { :; }
+`,
+	"wrap.sh": `
+deploy() { echo "deploying $*"; return 3; }
+build() { echo "building"; }
+`,
 }
 
 func testLoader(name string) ([]byte, error) {
-	return []byte(testScripts[name]), nil
+	s, ok := testScripts[name]
+	if !ok {
+		return nil, errors.New("no such script: " + name)
+	}
+	return []byte(s), nil
 }
 
 func TestHelloStdout(t *testing.T) {
@@ -103,7 +203,7 @@ func TestFuncCallback(t *testing.T) {
 	if status != 0 {
 		t.Fatal("non-zero exit")
 	}
-	if stdout.String() != "::: myfunc abc 123\n" {
+	if stdout.String() != bash.nonce+" myfunc abc 123\n" {
 		t.Fatal("unexpected stdout:", stdout.String())
 	}
 }
@@ -118,13 +218,15 @@ func TestFuncHandling(t *testing.T) {
 		exit <- 2
 	})
 
-	bash.HandleFuncs([]string{"thisprogram", ":::", "test-success"})
+	t.Setenv(callbackVar, "nonce")
+	bash.HandleFuncs([]string{"thisprogram", "nonce", "test-success"})
 	status := <-exit
 	if status != 0 {
 		t.Fatal("non-zero exit")
 	}
 
-	bash.HandleFuncs([]string{"thisprogram", ":::", "test-fail"})
+	t.Setenv(callbackVar, "nonce")
+	bash.HandleFuncs([]string{"thisprogram", "nonce", "test-fail"})
 	status = <-exit
 	if status != 2 {
 		t.Fatal("unexpected exit status:", status)
@@ -296,7 +398,8 @@ func TestWriteEnvfileContents(t *testing.T) {
 		"export FOOBAR=$'baz'\n",
 		"helper() { echo hi; }\n",
 		"export -f helper\n",
-		"myfunc() { $SELF_EXECUTABLE ::: myfunc \"$@\"; }\n",
+		"myfunc() { __basher_locate ",
+		" BASHER_CALLBACK=" + bash.nonce + " $SELF_EXECUTABLE " + bash.nonce + " myfunc \"$@\"; }\n",
 		`main() { echo "hello"; }` + "\n",
 	}
 	for _, w := range wants {
@@ -344,153 +447,6 @@ func TestBuildEnvfileWritesAndClosesFile(t *testing.T) {
 	}
 }
 
-func TestRestoreBashAtomicallyFreshDir(t *testing.T) {
-	dir := t.TempDir()
-	if err := restoreBashAtomically(dir); err != nil {
-		t.Fatal(err)
-	}
-
-	bashPath := filepath.Join(dir, "bash")
-	got, err := os.ReadFile(bashPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	want, err := Asset("bash")
-	if err != nil {
-		t.Fatal(err)
-	}
-	if sha256.Sum256(got) != sha256.Sum256(want) {
-		t.Fatalf("extracted bash bytes differ from embedded asset (got %d bytes, want %d)", len(got), len(want))
-	}
-
-	info, err := os.Stat(bashPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if info.Mode()&0111 == 0 {
-		t.Fatalf("extracted bash is not executable: mode=%v", info.Mode())
-	}
-}
-
-func TestRestoreBashAtomicallyNoOpWhenPresent(t *testing.T) {
-	dir := t.TempDir()
-	bashPath := filepath.Join(dir, "bash")
-	sentinel := []byte("sentinel-not-bash")
-	if err := os.WriteFile(bashPath, sentinel, 0755); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := restoreBashAtomically(dir); err != nil {
-		t.Fatal(err)
-	}
-
-	got, err := os.ReadFile(bashPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !bytes.Equal(got, sentinel) {
-		t.Fatalf("existing bash file was overwritten; got %q want %q", got, sentinel)
-	}
-}
-
-func TestRestoreBashAtomicallyNoTempLeftover(t *testing.T) {
-	dir := t.TempDir()
-	if err := restoreBashAtomically(dir); err != nil {
-		t.Fatal(err)
-	}
-
-	matches, err := filepath.Glob(filepath.Join(dir, "bash.tmp.*"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(matches) != 0 {
-		t.Fatalf("expected no bash.tmp.* leftovers, got %v", matches)
-	}
-}
-
-func TestRestoreBashAtomicallySweepsStaleTemp(t *testing.T) {
-	dir := t.TempDir()
-
-	stalePath := filepath.Join(dir, "bash.tmp.stale")
-	if err := os.WriteFile(stalePath, []byte("orphan"), 0600); err != nil {
-		t.Fatal(err)
-	}
-	old := time.Now().Add(-2 * time.Hour)
-	if err := os.Chtimes(stalePath, old, old); err != nil {
-		t.Fatal(err)
-	}
-
-	freshPath := filepath.Join(dir, "bash.tmp.fresh")
-	if err := os.WriteFile(freshPath, []byte("inflight"), 0600); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := restoreBashAtomically(dir); err != nil {
-		t.Fatal(err)
-	}
-
-	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
-		t.Fatalf("expected stale temp to be removed, stat err=%v", err)
-	}
-	if _, err := os.Stat(freshPath); err != nil {
-		t.Fatalf("expected fresh temp to be left alone, got err=%v", err)
-	}
-}
-
-func TestRestoreBashAtomicallyConcurrent(t *testing.T) {
-	dir := t.TempDir()
-
-	const workers = 16
-	start := make(chan struct{})
-	errs := make(chan error, workers)
-	var wg sync.WaitGroup
-	wg.Add(workers)
-	for i := 0; i < workers; i++ {
-		go func() {
-			defer wg.Done()
-			<-start
-			errs <- restoreBashAtomically(dir)
-		}()
-	}
-	close(start)
-	wg.Wait()
-	close(errs)
-	for err := range errs {
-		if err != nil {
-			t.Fatalf("concurrent restoreBashAtomically failed: %v", err)
-		}
-	}
-
-	bashPath := filepath.Join(dir, "bash")
-	got, err := os.ReadFile(bashPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	want, err := Asset("bash")
-	if err != nil {
-		t.Fatal(err)
-	}
-	if sha256.Sum256(got) != sha256.Sum256(want) {
-		t.Fatalf("torn write: extracted bash bytes differ from embedded asset (got %d bytes, want %d)", len(got), len(want))
-	}
-
-	info, err := os.Stat(bashPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if info.Mode()&0111 == 0 {
-		t.Fatalf("extracted bash is not executable: mode=%v", info.Mode())
-	}
-
-	matches, err := filepath.Glob(filepath.Join(dir, "bash.tmp.*"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(matches) != 0 {
-		t.Fatalf("expected no bash.tmp.* leftovers after concurrent run, got %v", matches)
-	}
-}
-
 func TestRunContextBackground(t *testing.T) {
 	bash, _ := NewContext(bashpath, false)
 	bash.Source("hello.sh", testLoader)
@@ -604,3 +560,78 @@ func TestIsBashFunc(t *testing.T) {
 		t.Fatal("bash func should be detected")
 	}
 }
+
+func TestFunctions(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("hello.sh", testLoader)
+	bash.Source("helpers.sh", testLoader)
+	bash.ExportFunc("myfunc", func([]string) {})
+
+	funcs, err := bash.Functions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]FunctionInfo)
+	for _, fn := range funcs {
+		got[fn.Name] = fn
+	}
+	wants := []FunctionInfo{
+		{Name: "main", Source: "hello.sh", Line: 1},
+		{Name: "helper", Source: "helpers.sh", Line: 2},
+		{Name: "other", Source: "helpers.sh", Line: 6},
+		{Name: "myfunc"},
+	}
+	for _, want := range wants {
+		if got[want.Name] != want {
+			t.Errorf("unexpected function info: got %+v want %+v", got[want.Name], want)
+		}
+	}
+	for _, name := range []string{"basher_emit", "basher_help"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("generated function %s should not be listed", name)
+		}
+	}
+}
+
+func TestRunFunctionNotFound(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("hello.sh", testLoader)
+	bash.Stderr = io.Discard
+
+	status, err := bash.Run("missing", []string{})
+	if !errors.Is(err, ErrFunctionNotFound) {
+		t.Fatalf("expected ErrFunctionNotFound, got %v", err)
+	}
+	if status != 127 {
+		t.Fatalf("unexpected exit status: %d", status)
+	}
+}
+
+func TestRunFunctionNotFoundSourcesOnce(t *testing.T) {
+	count := func(function string) int {
+		file := filepath.Join(t.TempDir(), "count")
+		bash, _ := NewContext(bashpath, false)
+		bash.Source("counted.sh", testLoader)
+		bash.Export("COUNT_FILE", file)
+		bash.Stderr = io.Discard
+		bash.Run(function, []string{})
+		data, _ := os.ReadFile(file)
+		return strings.Count(string(data), "x")
+	}
+	if found, missing := count("main"), count("missing"); missing != found {
+		t.Fatalf("scripts ran %d times for a missing command, %d for a defined one", missing, found)
+	}
+}
+
+func TestRunFunctionReturning127(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("exit127.sh", testLoader)
+
+	status, err := bash.Run("main", []string{})
+	if errors.Is(err, ErrFunctionNotFound) {
+		t.Fatal("defined function reported as not found")
+	}
+	if status != 127 {
+		t.Fatalf("unexpected exit status: %d", status)
+	}
+}
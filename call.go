@@ -0,0 +1,87 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A CallError is returned by Call and CallJSON when the Bash function could
+// not be run or exited with a non-zero status.
+type CallError struct {
+	// Func is the name of the Bash function that was called
+	Func string
+
+	// Status is the exit status of Bash
+	Status int
+
+	// Stderr is everything the function wrote to STDERR
+	Stderr string
+
+	// Err is the underlying error from running Bash
+	Err error
+}
+
+func (e *CallError) Error() string {
+	if e.Status == 0 && e.Err != nil {
+		// Bash did not run to completion, or failed after a clean exit
+		return fmt.Sprintf("basher: %s: %v", e.Func, e.Err)
+	}
+	msg := fmt.Sprintf("basher: %s exited with status %d", e.Func, e.Status)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// Call runs the Bash function fn with args and returns its STDOUT with
+// surrounding whitespace trimmed. Unlike Run, the Context's standard I/O is
+// not used: STDIN is empty and STDERR is captured into the returned
+// *CallError when fn exits with a non-zero status.
+func (c *Context) Call(ctx context.Context, fn string, args ...string) (string, error) {
+	out, err := c.call(ctx, fn, args)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// CallJSON runs the Bash function fn like Call and decodes its STDOUT as JSON
+// into out.
+func (c *Context) CallJSON(ctx context.Context, fn string, args []string, out any) error {
+	data, err := c.call(ctx, fn, args)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("basher: decoding output of %s: %w", fn, err)
+	}
+	return nil
+}
+
+func (c *Context) call(ctx context.Context, fn string, args []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	c.Lock()
+	status, err := c.run(ctx, &invocation{
+		command: fn,
+		args:    args,
+		stdout:  &stdout,
+		stderr:  &stderr,
+	})
+	c.Unlock()
+	if err != nil || status != 0 {
+		return nil, &CallError{
+			Func:   fn,
+			Status: status,
+			Stderr: stderr.String(),
+			Err:    err,
+		}
+	}
+	return stdout.Bytes(), nil
+}
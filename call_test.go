@@ -0,0 +1,81 @@
+package basher
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCall(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("call.sh", testLoader)
+
+	out, err := bash.Call(context.Background(), "greet", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello world" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestCallJSON(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("call.sh", testLoader)
+
+	var out struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	if err := bash.CallJSON(context.Background(), "info", []string{"widgets", "42"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "widgets" || out.Count != 42 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+}
+
+func TestCallJSONDecodeError(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("call.sh", testLoader)
+
+	var out map[string]any
+	err := bash.CallJSON(context.Background(), "greet", []string{"world"}, &out)
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+	var callErr *CallError
+	if errors.As(err, &callErr) {
+		t.Fatalf("decode failure reported as CallError: %v", err)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("call.sh", testLoader)
+
+	_, err := bash.Call(context.Background(), "fail")
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("expected *CallError, got %v", err)
+	}
+	if callErr.Status != 3 {
+		t.Fatalf("unexpected status: %d", callErr.Status)
+	}
+	if callErr.Stderr != "went wrong\n" {
+		t.Fatalf("unexpected stderr: %q", callErr.Stderr)
+	}
+	if callErr.Error() != "basher: fail exited with status 3: went wrong" {
+		t.Fatalf("unexpected message: %q", callErr.Error())
+	}
+}
+
+func TestCallFunctionNotFound(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("call.sh", testLoader)
+
+	_, err := bash.Call(context.Background(), "missing")
+	if !errors.Is(err, ErrFunctionNotFound) {
+		t.Fatalf("expected ErrFunctionNotFound, got %v", err)
+	}
+}
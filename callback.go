@@ -0,0 +1,88 @@
+package basher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// callbackVar is set by the Bash function produced by ExportFunc, and only
+// for the command calling back into the executable. It holds a nonce that
+// must also be the first argument, so neither arguments given by a user nor
+// a variable inherited from the environment alone look like a callback.
+const callbackVar = "BASHER_CALLBACK"
+
+// ErrInvalidCallback is returned by HandleCallback for a callback carrying
+// the right nonce but arguments no Bash function from go-basher would pass,
+// such as no function name.
+var ErrInvalidCallback = errors.New("basher: invalid callback")
+
+// newNonce returns a random nonce identifying callbacks from a Context, also
+// used to identify runs.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HandleCallback handles a callback to a Go function registered with
+// ExportFunc, which the executable is run with when the function is called
+// from Bash. It reports whether args, normally os.Args, are a callback and
+// returns an error wrapping ErrFunctionNotFound if the function called is
+// not registered, wrapping ErrInvalidCallback if its arguments are
+// malformed, or wrapping ErrExecutableChanged if the executable is not the
+// one that exported the function. Callbacks are recognized by a nonce
+// passed in both the environment and the arguments, never by arguments
+// alone. The nonce is removed from the environment before the function runs,
+// wrapped in any middleware added with Use.
+func (c *Context) HandleCallback(args []string) (bool, error) {
+	nonce := os.Getenv(callbackVar)
+	if nonce == "" || len(args) < 2 || args[1] != nonce {
+		return false, nil
+	}
+	os.Unsetenv(callbackVar)
+	if len(args) < 3 || args[2] == "" {
+		return true, fmt.Errorf("%w: no function named", ErrInvalidCallback)
+	}
+	caller := callerFromEnv()
+	bash := c.reentrant()
+	if err := checkSelfID(); err != nil {
+		return true, err
+	}
+	c.Lock()
+	fn, ok := c.funcs[args[2]]
+	var err error
+	if !ok {
+		fn, ok, err = c.hook(args[2], args[3:])
+	}
+	var h Handler
+	if ok && err == nil {
+		h = c.handler(fn)
+	}
+	c.Unlock()
+	if err != nil {
+		return true, err
+	}
+	if !ok {
+		return true, fmt.Errorf("%w: %s", ErrFunctionNotFound, args[2])
+	}
+	h(Callback{Name: args[2], Args: args[3:], Caller: caller, Context: bash})
+	return true, nil
+}
+
+// callback returns the Bash command calling back into the executable to run
+// the Go function name with the arguments of the Bash function, passing the
+// Caller from frame levels up the stack. Unless SelfPath has been changed to
+// another executable, the callback also carries the identity of this one.
+// The caller must hold the lock.
+func (c *Context) callback(name string, frame int) string {
+	vars := callerVars(frame) + " " + reentryVars() + " " + callbackVar + "=" + c.nonce
+	if c.exeID != "" && c.SelfPath == c.exePath {
+		vars += " " + selfIDVar + "=" + c.exeID
+	}
+	return fmt.Sprintf("%s $SELF_EXECUTABLE %s %s \"$@\"", vars, c.nonce, name)
+}
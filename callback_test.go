@@ -0,0 +1,93 @@
+package basher
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestHandleCallback(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	var got []string
+	bash.ExportFunc("myfunc", func(args []string) { got = args })
+
+	t.Setenv(callbackVar, "nonce")
+	handled, err := bash.HandleCallback([]string{"app", "nonce", "myfunc", ":::", "x"})
+	if !handled || err != nil {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+	if len(got) != 2 || got[0] != ":::" || got[1] != "x" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+	if _, ok := os.LookupEnv(callbackVar); ok {
+		t.Fatal("expected nonce to be removed from the environment")
+	}
+}
+
+func TestHandleCallbackIgnoresArgs(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.ExportFunc("myfunc", func([]string) { t.Fatal("unexpected callback") })
+
+	// the old marker, and a nonce without the environment, are just arguments
+	for _, args := range [][]string{
+		{"app", ":::", "myfunc"},
+		{"app", "nonce", "myfunc"},
+	} {
+		if handled, err := bash.HandleCallback(args); handled || err != nil {
+			t.Fatalf("unexpected result for %q: %v, %v", args, handled, err)
+		}
+	}
+
+	t.Setenv(callbackVar, "nonce")
+	for _, args := range [][]string{
+		{"app", "other", "myfunc"},
+		{"app", "myfunc", "nonce"},
+	} {
+		if handled, err := bash.HandleCallback(args); handled || err != nil {
+			t.Fatalf("unexpected result for %q: %v, %v", args, handled, err)
+		}
+	}
+}
+
+func TestHandleCallbackMalformed(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	for _, args := range [][]string{
+		{"app", "nonce"},
+		{"app", "nonce", ""},
+	} {
+		t.Setenv(callbackVar, "nonce")
+		handled, err := bash.HandleCallback(args)
+		if !handled || !errors.Is(err, ErrInvalidCallback) {
+			t.Fatalf("unexpected result for %q: %v, %v", args, handled, err)
+		}
+	}
+}
+
+func TestHandleCallbackNotFound(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	t.Setenv(callbackVar, "nonce")
+	handled, err := bash.HandleCallback([]string{"app", "nonce", "missing"})
+	if !handled || !errors.Is(err, ErrFunctionNotFound) {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+}
+
+func TestCallbackNonceNotInherited(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	t.Setenv(callbackVar, "nonce")
+	bash.CopyEnv()
+
+	// the nonce is only in the environment of the callback itself
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+	bash.Source("check.sh", func(string) ([]byte, error) {
+		return []byte(`check() { echo "${BASHER_CALLBACK-unset}"; }`), nil
+	})
+	if _, err := bash.Run("check", nil); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "unset\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
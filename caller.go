@@ -0,0 +1,132 @@
+package basher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The variables carrying a Caller, set only for the command calling back into
+// the executable, like callbackVar.
+const (
+	callerFuncVar   = "BASHER_CALLER_FUNC"
+	callerSourceVar = "BASHER_CALLER_SOURCE"
+	callerLineVar   = "BASHER_CALLER_LINE"
+	callerDirVar    = "BASHER_CALLER_DIR"
+	runIDVar        = "BASHER_RUN_ID"
+)
+
+// A Caller describes where in Bash an exported Go function was called from.
+type Caller struct {
+	// Func is the Bash function that made the call, empty at the top level
+	Func string
+
+	// Source and Line locate the call. Source is the path given to
+	// Context.Source for the script, or another file Bash read the code
+	// from. Both are empty for code generated by go-basher.
+	Source string
+	Line   int
+
+	// Dir is the working directory of Bash at the time of the call
+	Dir string
+
+	// RunID identifies the run of Bash that made the call. Scripts see it
+	// as BASHER_RUN_ID.
+	RunID string
+}
+
+// String formats the Caller for messages.
+func (c Caller) String() string {
+	s := c.Func
+	if s == "" {
+		s = "top level"
+	}
+	if c.Source != "" {
+		s += fmt.Sprintf(" at %s:%d", c.Source, c.Line)
+	}
+	return s
+}
+
+// callerFromEnv reads the Caller of a callback from the environment,
+// removing the variables carrying it.
+func callerFromEnv() Caller {
+	get := func(name string) string {
+		value := os.Getenv(name)
+		os.Unsetenv(name)
+		return value
+	}
+	caller := Caller{
+		Func:   get(callerFuncVar),
+		Source: get(callerSourceVar),
+		Dir:    get(callerDirVar),
+		RunID:  get(runIDVar),
+	}
+	caller.Line, _ = strconv.Atoi(get(callerLineVar))
+	return caller
+}
+
+// callerVars returns the assignments passing the Caller to a callback made
+// from the Bash function frame levels up the stack.
+func callerVars(frame int) string {
+	return fmt.Sprintf(`__basher_locate "${BASH_SOURCE[%d]-}" "${BASH_LINENO[%d]-}"; `+
+		`%s=${FUNCNAME[%d]-} %s=$__basher_source %s=$__basher_line %s=$PWD %s=${%s-}`,
+		frame, frame-1,
+		callerFuncVar, frame, callerSourceVar, callerLineVar, callerDirVar, runIDVar, runIDVar)
+}
+
+// locateFunc maps a location in the envfile back to the script it came from,
+// using the table written by writeScriptTable.
+const locateFunc = `__basher_locate() {
+	__basher_source= __basher_line=
+	if [[ -z $1 ]]; then
+		return
+	elif [[ $1 != "$__basher_envfile" ]]; then
+		__basher_source=$1 __basher_line=$2
+		return
+	elif (($2 < ${__basher_starts[0]-0} || $2 >= __basher_end)); then
+		return
+	fi
+	local i
+	for ((i = ${#__basher_starts[@]} - 1; i >= 0; i--)); do
+		if (($2 >= __basher_starts[i])); then
+			__basher_source=${__basher_sources[i]}
+			__basher_line=$(($2 - __basher_starts[i] + 1))
+			return
+		fi
+	done
+}
+`
+
+// writeScriptTable writes the names of the sourced scripts and the lines
+// they will start at, given that the table itself starts at line. The caller
+// must hold the lock.
+func (c *Context) writeScriptTable(w io.Writer, line int) {
+	names := make([]string, len(c.sources))
+	for i, source := range c.sources {
+		names[i] = shellQuote(source)
+	}
+	quoted := strings.Join(names, " ")
+	start := line + strings.Count(quoted, "\n") + 1
+	starts := make([]string, len(c.scripts))
+	for i, data := range c.scripts {
+		starts[i] = strconv.Itoa(start)
+		start += bytes.Count(data, []byte{'\n'}) + 1
+	}
+	fmt.Fprintf(w, "__basher_envfile=${BASH_SOURCE[0]} __basher_sources=(%s) __basher_starts=(%s) __basher_end=%d\n",
+		quoted, strings.Join(starts, " "), start)
+}
+
+// lineCounter counts the lines written through it.
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (lc *lineCounter) Write(p []byte) (int, error) {
+	n, err := lc.w.Write(p)
+	lc.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
@@ -0,0 +1,123 @@
+package basher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSelf returns an executable that prints the Caller variables it is
+// called back with.
+func fakeSelf(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "self")
+	script := "#!/bin/sh\nenv | grep '^BASHER_CALLER_\\|^BASHER_RUN_ID=' | sort\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCallbackCaller(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("first.sh", testLoader)
+	bash.Source("second.sh", testLoader)
+	bash.ExportFunc("myfunc", func([]string) {})
+	bash.SelfPath = fakeSelf(t)
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	if _, err := bash.Run("main", nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	runID := strings.TrimPrefix(lines[0], "run=")
+	if len(runID) != 32 {
+		t.Fatalf("unexpected run id: %q", lines[0])
+	}
+	expected := []string{
+		"BASHER_CALLER_DIR=/",
+		"BASHER_CALLER_FUNC=helper",
+		"BASHER_CALLER_LINE=3",
+		"BASHER_CALLER_SOURCE=second.sh",
+		"BASHER_RUN_ID=" + runID,
+	}
+	if got := strings.Join(lines[1:], "\n"); got != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected caller:\n%s", got)
+	}
+
+	// each run has its own id
+	stdout.Reset()
+	bash.Run("main", nil)
+	if strings.Contains(stdout.String(), runID) {
+		t.Fatal("expected a new run id")
+	}
+}
+
+func TestCallbackCallerTopLevel(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.ExportFunc("myfunc", func([]string) {})
+	bash.SelfPath = fakeSelf(t)
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	if _, err := bash.Run("myfunc", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"BASHER_CALLER_FUNC=\n", "BASHER_CALLER_SOURCE=\n", "BASHER_CALLER_LINE=\n"} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Fatalf("expected %q in:\n%s", line, stdout.String())
+		}
+	}
+}
+
+func TestHandleCallbackCaller(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	var got Caller
+	bash.ExportFunc("myfunc", func([]string) {})
+	bash.Use(func(next Handler) Handler {
+		return func(cb Callback) {
+			got = cb.Caller
+			next(cb)
+		}
+	})
+
+	t.Setenv(callbackVar, "nonce")
+	t.Setenv(callerFuncVar, "helper")
+	t.Setenv(callerSourceVar, "second.sh")
+	t.Setenv(callerLineVar, "3")
+	t.Setenv(callerDirVar, "/")
+	t.Setenv(runIDVar, "run")
+	if handled, err := bash.HandleCallback([]string{"app", "nonce", "myfunc"}); !handled || err != nil {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+	expected := Caller{Func: "helper", Source: "second.sh", Line: 3, Dir: "/", RunID: "run"}
+	if got != expected {
+		t.Fatalf("unexpected caller: %+v", got)
+	}
+	if got.String() != "helper at second.sh:3" {
+		t.Fatalf("unexpected string: %s", got)
+	}
+	if _, ok := os.LookupEnv(callerFuncVar); ok {
+		t.Fatal("expected caller to be removed from the environment")
+	}
+}
+
+func TestWrapBashFuncCaller(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("second.sh", testLoader)
+	bash.ExportFunc("myfunc", func([]string) {})
+	bash.WrapBashFunc("helper", func([]string) {}, nil)
+	bash.SelfPath = fakeSelf(t)
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	if _, err := bash.Run("main", nil); err != nil {
+		t.Fatal(err)
+	}
+	// the before hook is called from where main calls helper
+	if !strings.Contains(stdout.String(), "BASHER_CALLER_FUNC=main\nBASHER_CALLER_LINE=8\n") {
+		t.Fatalf("unexpected caller:\n%s", stdout.String())
+	}
+}
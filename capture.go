@@ -0,0 +1,397 @@
+package basher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ValueKind identifies the type of a Bash variable.
+type ValueKind int
+
+const (
+	// Scalar is a plain string variable
+	Scalar ValueKind = iota
+
+	// Indexed is an array declared with declare -a
+	Indexed
+
+	// Associative is an array declared with declare -A
+	Associative
+)
+
+// A Value is a Bash variable captured by RunCapture.
+type Value struct {
+	Kind ValueKind
+
+	// String holds the value of a Scalar
+	String string
+
+	// Array holds the elements of an Indexed array in index order. Bash
+	// arrays may be sparse, so Indexes holds the index of each element.
+	Array   []string
+	Indexes []int
+
+	// Map holds the elements of an Associative array
+	Map map[string]string
+
+	// Set is false when the variable was declared but never assigned
+	Set bool
+}
+
+// Result describes a completed run of Bash.
+type Result struct {
+	// Status is the exit status of Bash
+	Status int
+}
+
+// RunCapture is like RunContext, but once the command completes it reads the
+// named variables back out of Bash. Variables are dumped with declare -p
+// through a private file descriptor, so the command's output is untouched.
+// Variables that are not defined are left out of the returned map, as are all
+// variables if the command exits the shell rather than returning. As with Run,
+// a non-zero exit status is returned as an *exec.ExitError, alongside the
+// captured variables.
+func (c *Context) RunCapture(ctx context.Context, command string, args []string, vars ...string) (map[string]Value, Result, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, Result{}, err
+	}
+	defer r.Close()
+
+	inv := &invocation{
+		command: command,
+		args:    args,
+		stdin:   c.Stdin,
+		stdout:  c.Stdout,
+		stderr:  c.Stderr,
+	}
+	fd := inv.addFile(w, true)
+	if len(vars) > 0 {
+		names := make([]string, len(vars))
+		for i, name := range vars {
+			names[i] = shellQuote(name)
+		}
+		inv.epilogue = fmt.Sprintf("declare -p %s >&%d 2>/dev/null", strings.Join(names, " "), fd)
+	}
+
+	type readResult struct {
+		data []byte
+		err  error
+	}
+	read := make(chan readResult, 1)
+	go func() {
+		data, err := io.ReadAll(r)
+		read <- readResult{data, err}
+	}()
+
+	c.Lock()
+	status, err := c.run(ctx, inv)
+	c.Unlock()
+	dump := <-read
+	result := Result{Status: status}
+	// a non-zero exit still leaves the variables to be read
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, result, err
+	}
+	if dump.err != nil {
+		return nil, result, dump.err
+	}
+	values, parseErr := parseDeclare(string(dump.data))
+	if parseErr != nil {
+		return nil, result, parseErr
+	}
+	return values, result, err
+}
+
+// parseDeclare parses the output of declare -p into Values keyed by name.
+func parseDeclare(input string) (map[string]Value, error) {
+	p := &declareParser{input: input}
+	values := make(map[string]Value)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return values, nil
+		}
+		name, value, err := p.declaration()
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+}
+
+type declareParser struct {
+	input string
+	pos   int
+}
+
+func (p *declareParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *declareParser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *declareParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\n", p.peek()) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *declareParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("basher: parsing declare output at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// declaration parses a single `declare <flags> name[=value]` line.
+func (p *declareParser) declaration() (string, Value, error) {
+	if !strings.HasPrefix(p.input[p.pos:], "declare ") {
+		return "", Value{}, p.errorf("expected declare")
+	}
+	p.pos += len("declare ")
+
+	flags := ""
+	if !p.eof() && p.peek() == '-' {
+		end := strings.IndexByte(p.input[p.pos:], ' ')
+		if end < 0 {
+			return "", Value{}, p.errorf("unterminated flags")
+		}
+		flags = p.input[p.pos : p.pos+end]
+		p.pos += end + 1
+	}
+
+	start := p.pos
+	for !p.eof() && strings.IndexByte("=\n", p.peek()) < 0 {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	if name == "" {
+		return "", Value{}, p.errorf("missing variable name")
+	}
+
+	value := Value{Kind: Scalar}
+	switch {
+	case strings.Contains(flags, "A"):
+		value.Kind = Associative
+		value.Map = make(map[string]string)
+	case strings.Contains(flags, "a"):
+		value.Kind = Indexed
+		value.Array = make([]string, 0)
+		value.Indexes = make([]int, 0)
+	}
+	if p.eof() || p.peek() != '=' {
+		return name, value, nil
+	}
+	p.pos++
+	value.Set = true
+
+	if value.Kind == Scalar {
+		s, err := p.word()
+		if err != nil {
+			return "", Value{}, err
+		}
+		value.String = s
+		return name, value, nil
+	}
+
+	if p.eof() || p.peek() != '(' {
+		return "", Value{}, p.errorf("expected ( for array %s", name)
+	}
+	p.pos++
+	type element struct {
+		key   string
+		value string
+	}
+	elements := make([]element, 0)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return "", Value{}, p.errorf("unterminated array %s", name)
+		}
+		if p.peek() == ')' {
+			p.pos++
+			break
+		}
+		if p.peek() != '[' {
+			return "", Value{}, p.errorf("expected [ in array %s", name)
+		}
+		p.pos++
+		key, err := p.wordUntil(']')
+		if err != nil {
+			return "", Value{}, err
+		}
+		if p.eof() || p.peek() != ']' {
+			return "", Value{}, p.errorf("expected ] in array %s", name)
+		}
+		p.pos++
+		if p.eof() || p.peek() != '=' {
+			return "", Value{}, p.errorf("expected = in array %s", name)
+		}
+		p.pos++
+		val, err := p.word()
+		if err != nil {
+			return "", Value{}, err
+		}
+		elements = append(elements, element{key, val})
+	}
+
+	for _, e := range elements {
+		if value.Kind == Associative {
+			value.Map[e.key] = e.value
+			continue
+		}
+		index, err := strconv.Atoi(e.key)
+		if err != nil {
+			return "", Value{}, p.errorf("invalid index %q in array %s", e.key, name)
+		}
+		value.Array = append(value.Array, e.value)
+		value.Indexes = append(value.Indexes, index)
+	}
+	return name, value, nil
+}
+
+// word parses a shell word ending at unquoted whitespace or ')'.
+func (p *declareParser) word() (string, error) {
+	return p.wordUntil(0)
+}
+
+// wordUntil parses a shell word made of bare, '...', "..." and $'...'
+// segments, ending at unquoted whitespace, ')' or the given terminator.
+func (p *declareParser) wordUntil(term byte) (string, error) {
+	var b strings.Builder
+	for !p.eof() {
+		ch := p.peek()
+		switch {
+		case ch == term && term != 0:
+			return b.String(), nil
+		case strings.IndexByte(" \t\n)", ch) >= 0:
+			return b.String(), nil
+		case ch == '"':
+			p.pos++
+			if err := p.doubleQuoted(&b); err != nil {
+				return "", err
+			}
+		case ch == '\'':
+			p.pos++
+			end := strings.IndexByte(p.input[p.pos:], '\'')
+			if end < 0 {
+				return "", p.errorf("unterminated single quote")
+			}
+			b.WriteString(p.input[p.pos : p.pos+end])
+			p.pos += end + 1
+		case ch == '$' && p.pos+1 < len(p.input) && p.input[p.pos+1] == '\'':
+			p.pos += 2
+			if err := p.ansiQuoted(&b); err != nil {
+				return "", err
+			}
+		case ch == '\\' && p.pos+1 < len(p.input):
+			b.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(ch)
+			p.pos++
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *declareParser) doubleQuoted(b *strings.Builder) error {
+	for !p.eof() {
+		ch := p.peek()
+		p.pos++
+		switch ch {
+		case '"':
+			return nil
+		case '\\':
+			if p.eof() {
+				return p.errorf("unterminated double quote")
+			}
+			next := p.peek()
+			if strings.IndexByte("\"\\$`", next) >= 0 {
+				b.WriteByte(next)
+				p.pos++
+			} else if next == '\n' {
+				p.pos++
+			} else {
+				b.WriteByte('\\')
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return p.errorf("unterminated double quote")
+}
+
+func (p *declareParser) ansiQuoted(b *strings.Builder) error {
+	for !p.eof() {
+		ch := p.peek()
+		p.pos++
+		if ch == '\'' {
+			return nil
+		}
+		if ch != '\\' {
+			b.WriteByte(ch)
+			continue
+		}
+		if p.eof() {
+			break
+		}
+		esc := p.peek()
+		p.pos++
+		switch esc {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			b.WriteByte(esc)
+		case 'x':
+			b.WriteByte(byte(p.number(16, 2)))
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			p.pos--
+			b.WriteByte(byte(p.number(8, 3)))
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(esc)
+		}
+	}
+	return p.errorf("unterminated $' quote")
+}
+
+// number consumes up to max digits in the given base.
+func (p *declareParser) number(base int, max int) int {
+	n := 0
+	for i := 0; i < max && !p.eof(); i++ {
+		d := strings.IndexByte("0123456789abcdef", toLower(p.peek()))
+		if d < 0 || d >= base {
+			break
+		}
+		n = n*base + d
+		p.pos++
+	}
+	return n
+}
+
+func toLower(ch byte) byte {
+	if ch >= 'A' && ch <= 'Z' {
+		return ch + 'a' - 'A'
+	}
+	return ch
+}
@@ -0,0 +1,77 @@
+package basher
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRunCapture(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("capture.sh", testLoader)
+	bash.Stdout = io.Discard
+
+	vars, result, err := bash.RunCapture(context.Background(), "main", []string{},
+		"VERSION", "MESSAGE", "LIST", "MAP", "EMPTY", "MISSING")
+	if err == nil {
+		t.Fatal("expected exit error for status 4")
+	}
+	if result.Status != 4 {
+		t.Fatalf("unexpected status: %d", result.Status)
+	}
+
+	wants := map[string]Value{
+		"VERSION": {Kind: Scalar, String: "1.2.3", Set: true},
+		"MESSAGE": {Kind: Scalar, String: "multi\nline \"quoted\" $x \\ \001", Set: true},
+		"LIST":    {Kind: Indexed, Array: []string{"one", "two words", "x\ny", "z"}, Indexes: []int{0, 1, 2, 7}, Set: true},
+		"MAP":     {Kind: Associative, Map: map[string]string{"k": "v", "sp ace": `q"`, "n\nl": "1"}, Set: true},
+		"EMPTY":   {Kind: Scalar},
+	}
+	if !reflect.DeepEqual(vars, wants) {
+		t.Fatalf("unexpected vars:\ngot  %#v\nwant %#v", vars, wants)
+	}
+}
+
+func TestParseDeclare(t *testing.T) {
+	// bash 5.1 quotes scalars with double quotes, 5.2 switches to $'...'
+	// when the value contains control characters.
+	input := `declare -- s51="a \"q\" \$x \\ \` + "`b`" + `
+nl"
+declare -- s52=$'a "q" $x \\ \nnl\ttab \001'
+declare -a a=([0]="one" [1]="two words" [2]=$'x\ny' [7]="z")
+declare -A m=([k]="v" [$'n\nl']="1" ["sp ace"]="q\"" )
+declare -i n="5"
+declare -- e
+declare -a ea=()
+`
+	got, err := parseDeclare(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := map[string]Value{
+		"s51": {Kind: Scalar, String: "a \"q\" $x \\ `b`\nnl", Set: true},
+		"s52": {Kind: Scalar, String: "a \"q\" $x \\ \nnl\ttab \001", Set: true},
+		"a":   {Kind: Indexed, Array: []string{"one", "two words", "x\ny", "z"}, Indexes: []int{0, 1, 2, 7}, Set: true},
+		"m":   {Kind: Associative, Map: map[string]string{"k": "v", "n\nl": "1", "sp ace": `q"`}, Set: true},
+		"n":   {Kind: Scalar, String: "5", Set: true},
+		"e":   {Kind: Scalar},
+		"ea":  {Kind: Indexed, Array: []string{}, Indexes: []int{}, Set: true},
+	}
+	if !reflect.DeepEqual(got, wants) {
+		t.Fatalf("unexpected values:\ngot  %#v\nwant %#v", got, wants)
+	}
+}
+
+func TestParseDeclareInvalid(t *testing.T) {
+	for _, input := range []string{
+		"export FOO=bar\n",
+		`declare -- s="unterminated`,
+		`declare -a a=([0]="x"`,
+		`declare -a a=([x]="y")`,
+	} {
+		if _, err := parseDeclare(input); err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package basher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// completeCommand is the hidden argument that makes an App print completions
+// for the words after it instead of running.
+const completeCommand = "__complete"
+
+var completionScripts = map[string]string{
+	"bash": `_basher_complete_%[1]s() {
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _basher_complete_%[1]s %[2]s
+`,
+	"zsh": `#compdef %[2]s
+_basher_complete_%[1]s() {
+	local -a candidates
+	candidates=(${(f)"$("${words[1]}" __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	compadd -- $candidates
+}
+compdef _basher_complete_%[1]s %[2]s
+`,
+	"fish": `complete -c %[2]s -f -a '(%[2]s __complete (commandline -opc)[2..-1] (commandline -ct))'
+`,
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// WriteCompletion writes a completion script for program to w, for shell
+// being one of bash, zsh or fish. The script asks program for completions by
+// running it with __complete and the words on the command line, which an App
+// answers using Context.Complete.
+func WriteCompletion(w io.Writer, shell string, program string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("basher: no completion for shell %q", shell)
+	}
+	_, err := fmt.Fprintf(w, script, nonIdentifier.ReplaceAllString(program, "_"), program)
+	return err
+}
+
+// Complete returns the completions for the last of args, the words following
+// the program name, for an application using subcommands. Until a command is
+// named, the candidates are the commands that could follow. Once a command is
+// named, they are the flags from its Spec.
+//
+// The scripts are not run to find the commands. Instead the Bash functions
+// named cmd-<command> are found where their definitions start a line, so
+// functions defined with eval or in files sourced at run time are not
+// completed. Exported Go functions, like Bash ones, are only commands when
+// named cmd-<command>.
+func (c *Context) Complete(ctx context.Context, args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	word := args[len(args)-1]
+	prev := args[:len(args)-1]
+	commands := subcommands(c)
+	specs := c.Specs()
+	defined := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		defined[strings.Join(command, "-")] = true
+	}
+
+	candidates := make([]string, 0)
+	for n := len(prev); n > 0; n-- {
+		name := strings.Join(prev[:n], "-")
+		if !defined[name] {
+			continue
+		}
+		for _, flag := range specs[subcommandPrefix+name].Flags {
+			if strings.HasPrefix(flag.Name, word) {
+				candidates = append(candidates, flag.Name)
+			}
+		}
+		return candidates, nil
+	}
+
+	if len(prev) > 0 && prev[0] == "help" {
+		prev = prev[1:]
+	} else if len(prev) == 0 && strings.HasPrefix("help", word) {
+		candidates = append(candidates, "help")
+	}
+	seen := make(map[string]bool)
+	for _, command := range commands {
+		if len(command) <= len(prev) || !hasPrefix(command, prev) {
+			continue
+		}
+		next := command[len(prev)]
+		if strings.HasPrefix(next, word) && !seen[next] {
+			seen[next] = true
+			candidates = append(candidates, next)
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,116 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runComplete(t *testing.T, words ...string) []string {
+	var stdout bytes.Buffer
+	app := NewApp(AppConfig{
+		Funcs: map[string]func([]string){
+			"cmd-version": func([]string) {},
+		},
+		Specs: map[string]Spec{
+			"cmd-deploy": {Flags: []Flag{{Name: "--force"}, {Name: "--dry-run"}}},
+		},
+		Complete: func(args []string) []string {
+			if len(args) == 2 && args[0] == "deploy" && !strings.HasPrefix(args[1], "-") {
+				return []string{"prod", "staging"}
+			}
+			return nil
+		},
+		Scripts:     []string{"cli.sh"},
+		Loader:      testLoader,
+		Subcommands: true,
+		BashPath:    bashpath,
+		Args:        append([]string{"cli", "__complete"}, words...),
+		Stdout:      &stdout,
+	})
+	status, err := app.Main(context.Background())
+	if err != nil || status != 0 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	return strings.Fields(stdout.String())
+}
+
+func TestComplete(t *testing.T) {
+	for _, test := range []struct {
+		words    []string
+		expected string
+	}{
+		{nil, "help db deploy version"},
+		{[]string{""}, "help db deploy version"},
+		{[]string{"d"}, "db deploy"},
+		{[]string{"db", ""}, "migrate seed"},
+		{[]string{"help", "db", "m"}, "migrate"},
+		{[]string{"deploy", "--"}, "--force --dry-run"},
+		{[]string{"deploy", ""}, "--force --dry-run prod staging"},
+		{[]string{"nope", ""}, ""},
+	} {
+		got := strings.Join(runComplete(t, test.words...), " ")
+		if got != test.expected {
+			t.Fatalf("completing %q: expected %q, got %q", test.words, test.expected, got)
+		}
+	}
+}
+
+func TestCompleteWithoutRunningScripts(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "count")
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("counted.sh", testLoader)
+	bash.Source("keyword.sh", testLoader)
+	bash.Export("COUNT_FILE", file)
+	bash.ExportFunc("version", func([]string) {})
+
+	got, err := bash.Complete(context.Background(), []string{""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != "help db status" {
+		t.Fatalf("unexpected completions: %q", got)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("scripts were run to complete, stat err=%v", err)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var b bytes.Buffer
+		if err := WriteCompletion(&b, shell, "my-app"); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), "my-app") || !strings.Contains(b.String(), "__complete") {
+			t.Fatalf("unexpected %s completion:\n%s", shell, b.String())
+		}
+	}
+	if err := WriteCompletion(&bytes.Buffer{}, "tcsh", "my-app"); err == nil {
+		t.Fatal("expected error for unknown shell")
+	}
+}
+
+func TestBashCompletionScript(t *testing.T) {
+	var b bytes.Buffer
+	WriteCompletion(&b, "bash", "my-app")
+	// complete the words of a program that echoes them back
+	script := b.String() + `
+my-app() { shift; printf '%s\n' "$@"; }
+COMP_WORDS=(my-app db se)
+COMP_CWORD=2
+_basher_complete_my_app
+printf '%s,' "${COMPREPLY[@]}"
+`
+	out, err := exec.Command(bashpath, "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if string(out) != "db,se," {
+		t.Fatalf("unexpected completions: %q", out)
+	}
+}
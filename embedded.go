@@ -0,0 +1,253 @@
+package basher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// ErrNoEmbeddedBash is returned by Asset and EmbeddedBashPath when the
+// package is built with the basher_nobash tag, which leaves out the embedded
+// Bash binaries.
+var ErrNoEmbeddedBash = errors.New("basher: built without embedded bash (basher_nobash)")
+
+// ExtractDir, if set, is the directory the embedded Bash binary is extracted
+// under, overriding the BASHER_DIR environment variable and the default
+// locations chosen by EmbeddedBashPath.
+var ExtractDir string
+
+// MemoryBash, if true, makes EmbeddedBashPath load the embedded Bash binary
+// into an in-memory file instead of extracting it to disk. This is only
+// supported on Linux, where it is also used automatically when extraction
+// fails, for example because every candidate directory is mounted noexec.
+var MemoryBash bool
+
+// BashInfo describes the Bash binary embedded in this program.
+type BashInfo struct {
+	// Release is the bash-static release the binary was taken from
+	Release string
+
+	// Version is the version of Bash itself
+	Version BashVersion
+
+	// OS and Arch are the platform the binary was built for
+	OS   string
+	Arch string
+
+	// SHA256 is the hex-encoded SHA-256 of the uncompressed binary
+	SHA256 string
+
+	// Size and CompressedSize are the size of the binary in bytes, and
+	// how much space it takes up in this program
+	Size           int64
+	CompressedSize int64
+}
+
+func (i BashInfo) String() string {
+	return fmt.Sprintf("bash %s (bash-static %s) %s/%s sha256:%s %d bytes (%d compressed)",
+		i.Version, i.Release, i.OS, i.Arch, i.SHA256, i.Size, i.CompressedSize)
+}
+
+// EmbeddedBashInfo describes the embedded Bash binary without running or
+// extracting it. It returns ErrNoEmbeddedBash when built with the
+// basher_nobash tag.
+func EmbeddedBashInfo() (BashInfo, error) {
+	info, err := AssetInfo("bash")
+	if err != nil {
+		return BashInfo{}, err
+	}
+	version, err := embeddedBashVersionInfo()
+	if err != nil {
+		return BashInfo{}, err
+	}
+	return BashInfo{
+		Release:        bashStaticVersion,
+		Version:        version,
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		SHA256:         bashSHA256,
+		Size:           info.Size(),
+		CompressedSize: int64(len(bashGzip)),
+	}, nil
+}
+
+// embeddedBashVersionInfo returns the version of the embedded Bash, taken
+// from its bash-static release so that no extra process is needed.
+func embeddedBashVersionInfo() (BashVersion, error) {
+	return ParseBashVersion(strings.SplitN(bashStaticVersion, "-", 2)[0])
+}
+
+// embeddedBashVersion names the embedded bash by its bash-static release and
+// a prefix of its SHA-256. Each release of the asset is extracted into its own
+// directory, so binaries built against different versions of go-basher never
+// share a file.
+func embeddedBashVersion() string {
+	return bashStaticVersion + "-" + bashSHA256[:12]
+}
+
+// extractedDirPattern matches the directory names embeddedBashVersion
+// produces, which are the only entries PruneEmbeddedBash will remove.
+var extractedDirPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+-[0-9]+\.[0-9]+\.[0-9]+-[0-9a-f]{12}$`)
+
+// extractBaseDirs returns the directories the embedded bash may be extracted
+// under, in order of preference. ExtractDir and BASHER_DIR are explicit
+// choices and are used alone. Otherwise the XDG cache directory is tried
+// before a private directory under os.TempDir.
+func extractBaseDirs() []string {
+	if ExtractDir != "" {
+		return []string{ExtractDir}
+	}
+	if dir := os.Getenv("BASHER_DIR"); dir != "" {
+		return []string{dir}
+	}
+	dirs := make([]string, 0, 2)
+	if cache := os.Getenv("XDG_CACHE_HOME"); cache != "" {
+		dirs = append(dirs, filepath.Join(cache, "basher"))
+	} else if home, err := homedir.Dir(); err == nil && home != "" {
+		dirs = append(dirs, filepath.Join(home, ".cache", "basher"))
+	}
+	return append(dirs, privateTempDir())
+}
+
+// privateTempDir returns the per-user fallback extraction directory.
+func privateTempDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("basher-%d", os.Getuid()))
+}
+
+// preparePrivateTempDir creates dir if needed and makes sure it is a real
+// directory that only the current user can write to, since anyone able to
+// write there could replace the binary we are about to execute.
+func preparePrivateTempDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() || info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is not a private directory owned by the current user", dir)
+	}
+	return nil
+}
+
+// EmbeddedBashPath extracts the embedded Bash binary if it has not already
+// been and returns its path. The binary is placed in a directory named for
+// its bash-static release and checksum under ExtractDir if set, otherwise
+// under $BASHER_DIR if set, otherwise under $XDG_CACHE_HOME/basher (by
+// default ~/.cache/basher), falling back to a private directory under
+// os.TempDir when that cannot be written. On Linux, if extraction fails or
+// MemoryBash is set, the binary is run from memory instead.
+func EmbeddedBashPath() (string, error) {
+	if _, err := AssetInfo("bash"); err != nil {
+		return "", err
+	}
+	if MemoryBash {
+		return memoryBashPath()
+	}
+	path, err := extractEmbeddedBash()
+	if err == nil {
+		return path, nil
+	}
+	if path, memErr := memoryBashPath(); memErr == nil {
+		return path, nil
+	}
+	return "", err
+}
+
+// extractEmbeddedBash extracts the embedded bash under the first usable
+// directory from extractBaseDirs and returns its path.
+func extractEmbeddedBash() (string, error) {
+	failures := make([]string, 0)
+	for _, base := range extractBaseDirs() {
+		if base == privateTempDir() {
+			if err := preparePrivateTempDir(base); err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+		}
+		dir := filepath.Join(base, embeddedBashVersion())
+		if err := restoreBashAtomically(dir); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		return filepath.Join(dir, "bash"), nil
+	}
+	return "", fmt.Errorf("basher: unable to extract embedded bash: %s", strings.Join(failures, "; "))
+}
+
+// PruneEmbeddedBash removes Bash binaries extracted by other versions of
+// go-basher, keeping the current one and the most recently extracted others
+// up to keep in total. The versioned directories in ~/.basher, where earlier
+// releases extracted them, are pruned the same way, but ~/.basher/bash is
+// left for the programs built with releases that still run it.
+func PruneEmbeddedBash(keep int) error {
+	current := embeddedBashVersion()
+	bases := extractBaseDirs()
+	if legacy, err := homedir.Expand("~/.basher"); err == nil {
+		bases = append(bases, legacy)
+	}
+	seen := make(map[string]bool)
+	for _, base := range bases {
+		if seen[base] {
+			continue
+		}
+		seen[base] = true
+		if err := pruneBashDirs(base, current, keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneBashDirs removes extracted bash directories in base other than
+// current, keeping the keep-1 most recently extracted of them. Only
+// directories named like embeddedBashVersion are considered, so pointing
+// BASHER_DIR at a shared directory never removes anything else in it.
+func pruneBashDirs(base, current string, keep int) error {
+	entries, err := os.ReadDir(base)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	type version struct {
+		name    string
+		modTime int64
+	}
+	stale := make([]version, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == current || !extractedDirPattern.MatchString(name) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(base, name, "bash"))
+		if err != nil {
+			continue
+		}
+		stale = append(stale, version{name, info.ModTime().UnixNano()})
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].modTime > stale[j].modTime
+	})
+
+	for i, v := range stale {
+		if i < keep-1 {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(base, v.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+//go:build !basher_nobash
+// +build !basher_nobash
+
+package basher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// setTestHome points HOME and TMPDIR at fresh directories and clears the
+// variables that choose where the embedded bash is extracted.
+func setTestHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("BASHER_DIR", "")
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+	return home
+}
+
+func TestEmbeddedBashPath(t *testing.T) {
+	home := setTestHome(t)
+
+	path, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(home, ".cache", "basher", bashStaticVersion+"-"+bashSHA256[:12], "bash")
+	if path != want {
+		t.Fatalf("unexpected path: got %s want %s", path, want)
+	}
+	if !verifyBash(filepath.Dir(path)) {
+		t.Fatal("extracted bash does not verify")
+	}
+}
+
+func TestEmbeddedBashPathLocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, root string)
+		want  string
+	}{
+		{
+			name: "ExtractDir",
+			setup: func(t *testing.T, root string) {
+				t.Setenv("BASHER_DIR", filepath.Join(root, "ignored"))
+				ExtractDir = filepath.Join(root, "explicit")
+				t.Cleanup(func() { ExtractDir = "" })
+			},
+			want: "explicit",
+		},
+		{
+			name: "BASHER_DIR",
+			setup: func(t *testing.T, root string) {
+				t.Setenv("BASHER_DIR", filepath.Join(root, "env"))
+				t.Setenv("XDG_CACHE_HOME", filepath.Join(root, "ignored"))
+			},
+			want: "env",
+		},
+		{
+			name: "XDG_CACHE_HOME",
+			setup: func(t *testing.T, root string) {
+				t.Setenv("XDG_CACHE_HOME", filepath.Join(root, "xdg"))
+			},
+			want: filepath.Join("xdg", "basher"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestHome(t)
+			root := t.TempDir()
+			tt.setup(t, root)
+
+			path, err := EmbeddedBashPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := filepath.Join(root, tt.want, embeddedBashVersion(), "bash")
+			if path != want {
+				t.Fatalf("unexpected path: got %s want %s", path, want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedBashPathTempFallback(t *testing.T) {
+	setTestHome(t)
+	// a regular file where the cache directory should be cannot be written
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CACHE_HOME", blocked)
+
+	path, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(privateTempDir(), embeddedBashVersion(), "bash")
+	if path != want {
+		t.Fatalf("unexpected path: got %s want %s", path, want)
+	}
+	info, err := os.Stat(privateTempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("temp fallback is not private: mode=%v", info.Mode())
+	}
+}
+
+func TestEmbeddedBashPathUnwritable(t *testing.T) {
+	setTestHome(t)
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ExtractDir = blocked
+	defer func() { ExtractDir = "" }()
+
+	_, err := extractEmbeddedBash()
+	if err == nil {
+		t.Fatal("expected error extracting under a regular file")
+	}
+	if !strings.Contains(err.Error(), "unable to extract embedded bash") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPreparePrivateTempDirRejectsShared(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := preparePrivateTempDir(dir); err == nil {
+		t.Fatal("expected world-writable directory to be rejected")
+	}
+}
+
+func TestPruneEmbeddedBash(t *testing.T) {
+	home := setTestHome(t)
+	current, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Dir(filepath.Dir(current))
+	now := time.Now()
+	old := []string{"5.0.016-1.2.1-000000000001", "5.0.016-1.2.1-000000000002", "5.0.016-1.2.1-000000000003"}
+	for i, name := range old {
+		dir := filepath.Join(base, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		bashPath := filepath.Join(dir, "bash")
+		if err := os.WriteFile(bashPath, []byte("old"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		// old[0] is the most recently used
+		modTime := now.Add(-time.Duration(i+1) * time.Hour)
+		if err := os.Chtimes(bashPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// anything not named like an extraction is left alone, even a bash
+	// binary or a directory holding one
+	if err := os.MkdirAll(filepath.Join(base, "unrelated"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"bash", filepath.Join("unrelated", "bash")} {
+		if err := os.WriteFile(filepath.Join(base, path), []byte("mine"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// binaries extracted into ~/.basher by earlier releases
+	legacy := filepath.Join(home, ".basher")
+	if err := os.MkdirAll(filepath.Join(legacy, "5.0.016-1.2.1-0123456789ab"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"bash", filepath.Join("5.0.016-1.2.1-0123456789ab", "bash")} {
+		if err := os.WriteFile(filepath.Join(legacy, path), []byte("legacy"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PruneEmbeddedBash(2); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	got := strings.Join(names, ",")
+	want := strings.Join([]string{old[0], filepath.Base(filepath.Dir(current)), "bash", "unrelated"}, ",")
+	if got != want {
+		t.Fatalf("unexpected entries after prune: got %s want %s", got, want)
+	}
+	legacyDir := filepath.Join(legacy, "5.0.016-1.2.1-0123456789ab")
+	if _, err := os.Stat(legacyDir); err != nil {
+		t.Fatalf("expected the only version in ~/.basher to be kept: %v", err)
+	}
+
+	if err := PruneEmbeddedBash(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(base, old[0])); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be pruned, stat err=%v", old[0], err)
+	}
+	if _, err := os.Stat(current); err != nil {
+		t.Fatalf("current bash was pruned: %v", err)
+	}
+	if _, err := os.Stat(legacyDir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be pruned, stat err=%v", legacyDir, err)
+	}
+	// still run by programs built with older releases
+	if _, err := os.Stat(filepath.Join(legacy, "bash")); err != nil {
+		t.Fatalf("legacy ~/.basher/bash was removed: %v", err)
+	}
+}
+
+func TestFindBashPreferEmbedded(t *testing.T) {
+	setTestHome(t)
+	t.Setenv("BASHER_BASH", "")
+	t.Setenv("SHELL", bashpath)
+
+	path, version, err := FindBash(Requirements{PreferEmbedded: true, MinVersion: "4.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != embedded {
+		t.Fatalf("expected embedded bash, got %s", path)
+	}
+	probed, err := bashVersion(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != probed {
+		t.Fatalf("embedded version %v does not match BASH_VERSINFO %v", version, probed)
+	}
+}
+
+func TestEmbeddedBashInfo(t *testing.T) {
+	info, err := EmbeddedBashInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Asset("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Release != bashStaticVersion || info.SHA256 != bashSHA256 {
+		t.Fatalf("unexpected release or checksum: %+v", info)
+	}
+	if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Fatalf("unexpected platform: %s/%s", info.OS, info.Arch)
+	}
+	if info.Size != int64(len(data)) {
+		t.Fatalf("unexpected size: got %d want %d", info.Size, len(data))
+	}
+	if info.CompressedSize <= 0 || info.CompressedSize >= info.Size {
+		t.Fatalf("unexpected compressed size: %d", info.CompressedSize)
+	}
+
+	setTestHome(t)
+	path, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	probed, err := bashVersion(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != probed {
+		t.Fatalf("version %v does not match BASH_VERSINFO %v", info.Version, probed)
+	}
+}
+
+func TestWriteBasherVersion(t *testing.T) {
+	var out bytes.Buffer
+	writeBasherVersion(&out, bashpath)
+
+	info, err := EmbeddedBashInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := bashVersion(bashpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bash: " + bashpath + " (" + version.String() + ")\nembedded: " + info.String() + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", out.String(), want)
+	}
+}
@@ -0,0 +1,137 @@
+package basher
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventFDVar names the environment variable holding the descriptor that
+// basher_emit writes events to.
+const eventFDVar = "BASHER_EVENT_FD"
+
+// eventDrainTimeout is how long to wait for more events once Bash has exited
+// and the pipe is empty. Background processes inherit the pipe, so waiting
+// for it to close could block a run for as long as they live.
+const eventDrainTimeout = 100 * time.Millisecond
+
+// emitFunc is written to every envfile. Each event is formatted as a single
+// line of %q-quoted words and sent with one write, so events from concurrent
+// emitters do not interleave as long as they are smaller than PIPE_BUF. It
+// does nothing when the Context has no OnEvent callback.
+const emitFunc = `basher_emit() {
+	[[ -n "$BASHER_EVENT_FD" ]] || return 0
+	local line
+	printf -v line '%q ' "$@"
+	printf '%s\n' "$line" >&"$BASHER_EVENT_FD"
+}
+export -f basher_emit
+`
+
+// An Event is a structured message sent from Bash to the Context's OnEvent
+// callback with:
+//
+//	basher_emit type key=value ...
+//
+// Arguments without an "=" are recorded as fields with an empty value.
+type Event struct {
+	Type   string
+	Fields map[string]string
+}
+
+// wireEvents passes a pipe for basher_emit to inv and starts delivering
+// events from it to OnEvent. It returns the environment variable naming the
+// descriptor and a function that delivers the events left in the pipe once
+// Bash has exited, giving up when nothing more arrives within
+// eventDrainTimeout.
+func (c *Context) wireEvents(inv *invocation) (string, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", nil, err
+	}
+	fd := inv.addFile(w, false)
+	dr := &drainReader{f: r, draining: make(chan struct{})}
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		defer r.Close()
+		readEvents(dr, c.OnEvent)
+	}()
+	wait := func() {
+		w.Close()
+		close(dr.draining)
+		// wakes a read already waiting on the pipe
+		r.SetReadDeadline(time.Now().Add(eventDrainTimeout))
+		<-delivered
+	}
+	return eventFDVar + "=" + strconv.Itoa(fd), wait, nil
+}
+
+// A drainReader reads the event pipe, and once draining is closed, gives up
+// when no data arrives within eventDrainTimeout of each read. The deadline
+// is set anew for every read, so a slow OnEvent doesn't lose the events
+// still buffered in the pipe.
+type drainReader struct {
+	f        *os.File
+	draining chan struct{}
+}
+
+func (dr *drainReader) Read(p []byte) (int, error) {
+	select {
+	case <-dr.draining:
+		dr.f.SetReadDeadline(time.Now().Add(eventDrainTimeout))
+	default:
+	}
+	return dr.f.Read(p)
+}
+
+// readEvents parses events from r and passes them to fn in order until r is
+// exhausted. Lines that cannot be parsed are skipped.
+func readEvents(r io.Reader, fn func(Event)) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if event, ok := parseEvent(strings.TrimSuffix(line, "\n")); ok {
+				fn(event)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseEvent parses a line written by basher_emit.
+func parseEvent(line string) (Event, bool) {
+	p := &declareParser{input: line}
+	words := make([]string, 0)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		start := p.pos
+		word, err := p.word()
+		if err != nil || p.pos == start {
+			return Event{}, false
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 || words[0] == "" {
+		return Event{}, false
+	}
+	event := Event{Type: words[0], Fields: make(map[string]string)}
+	for _, word := range words[1:] {
+		pair := strings.SplitN(word, "=", 2)
+		if len(pair) == 2 {
+			event.Fields[pair[0]] = pair[1]
+		} else {
+			event.Fields[pair[0]] = ""
+		}
+	}
+	return event, true
+}
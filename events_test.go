@@ -0,0 +1,152 @@
+package basher
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	var events []Event
+	bash.OnEvent = func(e Event) {
+		events = append(events, e)
+	}
+
+	out, err := bash.Call(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "stdout is untouched" {
+		t.Fatalf("unexpected stdout: %q", out)
+	}
+
+	wants := []Event{
+		{Type: "progress", Fields: map[string]string{
+			"step":    "build",
+			"message": "hello world",
+			"multi":   "a\nb",
+			"flag":    "",
+		}},
+		{Type: "done", Fields: map[string]string{}},
+	}
+	if !reflect.DeepEqual(events, wants) {
+		t.Fatalf("unexpected events:\ngot  %#v\nwant %#v", events, wants)
+	}
+}
+
+func TestEventsHighVolumeOrdering(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	next := 0
+	bash.OnEvent = func(e Event) {
+		if e.Type != "tick" || e.Fields["seq"] != strconv.Itoa(next) {
+			t.Errorf("out of order event %d: %+v", next, e)
+		}
+		next++
+	}
+
+	const count = 5000
+	if _, err := bash.Call(context.Background(), "flood", strconv.Itoa(count)); err != nil {
+		t.Fatal(err)
+	}
+	if next != count {
+		t.Fatalf("expected %d events, got %d", count, next)
+	}
+}
+
+func TestEventsSlowConsumer(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	next := 0
+	bash.OnEvent = func(e Event) {
+		if e.Fields["seq"] != strconv.Itoa(next) {
+			t.Errorf("out of order event %d: %+v", next, e)
+		}
+		next++
+		// far slower than Bash emits, so most events are read after it exits
+		time.Sleep(time.Millisecond)
+	}
+
+	const count = 1000
+	if _, err := bash.Call(context.Background(), "flood", strconv.Itoa(count)); err != nil {
+		t.Fatal(err)
+	}
+	if next != count {
+		t.Fatalf("expected %d events, got %d", count, next)
+	}
+}
+
+func TestEventsFromChildProcess(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	var events []Event
+	bash.OnEvent = func(e Event) {
+		events = append(events, e)
+	}
+
+	if _, err := bash.Call(context.Background(), "nested"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != "child" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestEventsWithBackgroundProcess(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	var events []Event
+	bash.OnEvent = func(e Event) {
+		events = append(events, e)
+	}
+
+	start := time.Now()
+	if _, err := bash.Call(context.Background(), "background"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("run waited for the background process: %v", elapsed)
+	}
+	if len(events) != 1 || events[0].Type != "started" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestEmitWithoutOnEvent(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("events.sh", testLoader)
+	bash.Export(eventFDVar, "9")
+
+	out, err := bash.Call(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "stdout is untouched" {
+		t.Fatalf("unexpected stdout: %q", out)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	event, ok := parseEvent(`deploy target=prod $'note=line\none' empty=''`)
+	if !ok {
+		t.Fatal("failed to parse event")
+	}
+	want := Event{Type: "deploy", Fields: map[string]string{
+		"target": "prod",
+		"note":   "line\none",
+		"empty":  "",
+	}}
+	if !reflect.DeepEqual(event, want) {
+		t.Fatalf("unexpected event: %#v", event)
+	}
+
+	for _, line := range []string{"", "  ", `"unterminated`, ")"} {
+		if _, ok := parseEvent(line); ok {
+			t.Errorf("expected %q to be rejected", line)
+		}
+	}
+}
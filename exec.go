@@ -0,0 +1,50 @@
+package basher
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Exec replaces the Go process with Bash running command, rather than
+// running Bash as a child as Run does. Exported Go functions keep working, as
+// they call back into a new process of SELF_EXECUTABLE. Exec only returns if
+// Bash could not be started.
+//
+// With no Go process left behind, some features are unavailable: there is no
+// context to cancel, OnEvent and exported streams are rejected, Bash inherits
+// the standard I/O of the process so Stdin, Stdout and Stderr must not be
+// changed, and a missing command is not reported as ErrFunctionNotFound. The
+// environment is kept in a memfd on Linux; elsewhere the temporary BASH_ENV
+// file is left behind.
+func (c *Context) Exec(command string, args []string) error {
+	c.Lock()
+	defer c.Unlock()
+	switch {
+	case c.OnEvent != nil:
+		return fmt.Errorf("basher: Exec does not support OnEvent")
+	case len(c.streams) > 0:
+		return fmt.Errorf("basher: Exec does not support exported streams")
+	case c.Stdin != os.Stdin || c.Stdout != os.Stdout || c.Stderr != os.Stderr:
+		return fmt.Errorf("basher: Exec does not support redirected standard I/O")
+	case c.inherited != "":
+		return fmt.Errorf("basher: Exec is not supported from a callback")
+	}
+
+	if err := c.inheritPinned(); err != nil {
+		return err
+	}
+	envfile, cleanup, err := c.execEnvfile()
+	if err != nil {
+		return err
+	}
+	runID, err := newNonce()
+	if err != nil {
+		cleanup()
+		return err
+	}
+	inv := &invocation{command: command, args: args, runID: runID}
+	err = syscall.Exec(c.BashPath, []string{"bash", "-c", inv.script(envfile)}, []string{})
+	cleanup()
+	return err
+}
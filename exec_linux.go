@@ -0,0 +1,31 @@
+package basher
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// execEnvfile writes the environment for Exec into a memfd that survives the
+// exec, and returns the path Bash sources it from along with a function that
+// closes it should the exec fail. Bash reads a sourced file whole before
+// running it, so the file closes its own descriptor at the end.
+func (c *Context) execEnvfile() (string, func(), error) {
+	fd, err := unix.MemfdCreate("bashenv", 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("basher: memfd_create: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "memfd:bashenv")
+	cleanup := func() { f.Close() }
+
+	if err := c.writeEnvfile(f); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := fmt.Fprintf(f, "exec %d<&-\n", fd); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return fmt.Sprintf("/dev/fd/%d", fd), cleanup, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package basher
+
+import "os"
+
+// execEnvfile writes the environment for Exec into a temporary file, which
+// is left behind once Bash replaces the process. The returned function
+// removes it should the exec fail.
+func (c *Context) execEnvfile() (string, func(), error) {
+	envfile, err := c.buildEnvfile()
+	if err != nil {
+		return "", nil, err
+	}
+	return envfile, func() { os.Remove(envfile) }, nil
+}
@@ -0,0 +1,68 @@
+package basher
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// execEnv makes the helper App replace itself with Bash.
+const execEnv = "BASHER_TEST_EXEC"
+
+func init() {
+	helperApp.Exec = os.Getenv(execEnv) != ""
+	helperApp.MultiCall = append(helperApp.MultiCall, "showpid")
+}
+
+func TestAppExec(t *testing.T) {
+	dir := installTestLinks(t)
+	env := []string{execEnv + "=1"}
+
+	if out := runMultiCallEnv(t, env, filepath.Join(dir, "greet"), "world"); out != "hello world\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	// callbacks into Go still work once the process is Bash
+	if out := runMultiCallEnv(t, env, filepath.Join(dir, "shout"), "hey"); out != "HEY\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	self, _ := os.Executable()
+	if out := runMultiCallEnv(t, env, self, "a", "b"); out != "main a b\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestAppExecReplacesProcess(t *testing.T) {
+	dir := installTestLinks(t)
+	var stdout bytes.Buffer
+	cmd := exec.Command(filepath.Join(dir, "showpid"))
+	cmd.Env = append(os.Environ(), multiCallEnv+"=1", execEnv+"=1")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != cmd.Process.Pid {
+		t.Fatalf("expected Bash to run as pid %d, got %d", cmd.Process.Pid, pid)
+	}
+}
+
+func TestExecUnsupported(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.OnEvent = func(Event) {}
+	if err := bash.Exec("true", nil); err == nil || !strings.Contains(err.Error(), "OnEvent") {
+		t.Fatalf("expected OnEvent error, got %v", err)
+	}
+
+	bash, _ = NewContext(bashpath, false)
+	bash.Stdout = &bytes.Buffer{}
+	if err := bash.Exec("true", nil); err == nil || !strings.Contains(err.Error(), "standard I/O") {
+		t.Fatalf("expected standard I/O error, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package basher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Requirements constrain the Bash chosen by FindBash.
+type Requirements struct {
+	// MinVersion is the oldest acceptable Bash version, such as "4.4".
+	// Any version is accepted when empty.
+	MinVersion string
+
+	// PreferEmbedded tries the embedded Bash before any Bash installed on
+	// the system. $BASHER_BASH still takes precedence.
+	PreferEmbedded bool
+
+	// SearchPath is a list of directories in the format of $PATH to look
+	// for bash in. $PATH is used when empty.
+	SearchPath string
+}
+
+// A BashVersion is the version of a Bash binary as reported by BASH_VERSINFO.
+type BashVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func (v BashVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is older than other.
+func (v BashVersion) Less(other BashVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// ParseBashVersion parses a version such as "4.4" or "5.1.8". Missing
+// components are zero.
+func ParseBashVersion(s string) (BashVersion, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 3 {
+		return BashVersion{}, fmt.Errorf("basher: invalid bash version %q", s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return BashVersion{}, fmt.Errorf("basher: invalid bash version %q", s)
+		}
+		nums[i] = n
+	}
+	return BashVersion{nums[0], nums[1], nums[2]}, nil
+}
+
+// FindBash chooses a Bash binary satisfying req and returns its path and
+// version. If $BASHER_BASH is set, it is used, and an error is returned when
+// it can't be run or is too old. Otherwise candidates are tried in order:
+// $SHELL if it names a bash, then bash on the search path, then the embedded
+// Bash, which moves to the front when req.PreferEmbedded is set.
+func FindBash(req Requirements) (string, BashVersion, error) {
+	var min BashVersion
+	if req.MinVersion != "" {
+		v, err := ParseBashVersion(req.MinVersion)
+		if err != nil {
+			return "", BashVersion{}, err
+		}
+		min = v
+	}
+
+	// an explicit choice is never passed over for another bash
+	if path := os.Getenv("BASHER_BASH"); path != "" {
+		version, err := bashVersion(path)
+		if err != nil {
+			return "", BashVersion{}, fmt.Errorf("basher: BASHER_BASH=%s: %v", path, err)
+		}
+		if version.Less(min) {
+			return "", BashVersion{}, fmt.Errorf("basher: BASHER_BASH=%s: version %s is older than %s", path, version, min)
+		}
+		return path, version, nil
+	}
+
+	type candidate func() (string, error)
+	fromShell := func() (string, error) {
+		shell := os.Getenv("SHELL")
+		if !strings.Contains(filepath.Base(shell), "bash") {
+			return "", nil
+		}
+		return shell, nil
+	}
+	fromPath := func() (string, error) {
+		return lookPathIn("bash", req.SearchPath)
+	}
+	fromEmbedded := EmbeddedBashPath
+	candidates := []candidate{fromShell, fromPath, fromEmbedded}
+	if req.PreferEmbedded {
+		candidates = []candidate{fromEmbedded, fromShell, fromPath}
+	}
+
+	failures := make([]string, 0)
+	for _, lookup := range candidates {
+		path, err := lookup()
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if path == "" {
+			continue
+		}
+		// the embedded Bash is probed too, since the asset for a platform
+		// may not be a working binary
+		version, err := bashVersion(path)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		if version.Less(min) {
+			failures = append(failures, fmt.Sprintf("%s: version %s is older than %s", path, version, min))
+			continue
+		}
+		return path, version, nil
+	}
+	if len(failures) == 0 {
+		return "", BashVersion{}, fmt.Errorf("basher: no bash found")
+	}
+	return "", BashVersion{}, fmt.Errorf("basher: no suitable bash found: %s", strings.Join(failures, "; "))
+}
+
+// lookPathIn finds an executable named file in the directories listed in
+// searchPath, or $PATH if searchPath is empty. It returns an empty path
+// without error if there is none.
+func lookPathIn(file string, searchPath string) (string, error) {
+	if searchPath == "" {
+		path, err := exec.LookPath(file)
+		if err != nil {
+			return "", nil
+		}
+		return path, nil
+	}
+	for _, dir := range filepath.SplitList(searchPath) {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// bashVersion runs the Bash at path to find out its version.
+func bashVersion(path string) (BashVersion, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(path, "-c", `echo "${BASH_VERSINFO[0]}.${BASH_VERSINFO[1]}.${BASH_VERSINFO[2]}"`)
+	cmd.Env = []string{}
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return BashVersion{}, err
+	}
+	return ParseBashVersion(stdout.String())
+}
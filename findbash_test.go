@@ -0,0 +1,186 @@
+package basher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBash writes an executable named bash under dir/name that reports the
+// given version when probed by FindBash.
+func fakeBash(t *testing.T, dir, name, version string) string {
+	bin := filepath.Join(dir, name)
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(bin, "bash")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setFindBashEnv isolates FindBash from the environment running the tests.
+func setFindBashEnv(t *testing.T) {
+	t.Setenv("BASHER_BASH", "")
+	t.Setenv("SHELL", "")
+	t.Setenv("PATH", "")
+	t.Setenv("BASHER_DIR", t.TempDir())
+}
+
+func TestParseBashVersion(t *testing.T) {
+	tests := map[string]BashVersion{
+		"4.4":     {4, 4, 0},
+		"5.1.8":   {5, 1, 8},
+		"5.1.008": {5, 1, 8},
+		"3\n":     {3, 0, 0},
+	}
+	for input, want := range tests {
+		got, err := ParseBashVersion(input)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("parsing %q: got %v want %v", input, got, want)
+		}
+	}
+	for _, input := range []string{"", "four", "1.2.3.4", "-1"} {
+		if _, err := ParseBashVersion(input); err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+
+	if !(BashVersion{4, 3, 48}).Less(BashVersion{4, 4, 0}) {
+		t.Fatal("4.3.48 should be older than 4.4.0")
+	}
+	if (BashVersion{5, 0, 0}).Less(BashVersion{4, 4, 0}) {
+		t.Fatal("5.0.0 should not be older than 4.4.0")
+	}
+}
+
+func TestFindBashOverride(t *testing.T) {
+	setFindBashEnv(t)
+	dir := t.TempDir()
+	override := fakeBash(t, dir, "override", "5.2.15")
+	t.Setenv("BASHER_BASH", override)
+	t.Setenv("SHELL", fakeBash(t, dir, "shell", "5.0.0"))
+
+	path, version, err := FindBash(Requirements{PreferEmbedded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != override {
+		t.Fatalf("expected BASHER_BASH to win, got %s", path)
+	}
+	if version != (BashVersion{5, 2, 15}) {
+		t.Fatalf("unexpected version: %v", version)
+	}
+}
+
+func TestFindBashMinVersion(t *testing.T) {
+	setFindBashEnv(t)
+	dir := t.TempDir()
+	t.Setenv("SHELL", fakeBash(t, dir, "shell", "3.2.57"))
+	newer := fakeBash(t, dir, "newer", "4.4.23")
+
+	path, version, err := FindBash(Requirements{
+		MinVersion: "4.4",
+		SearchPath: filepath.Join(dir, "missing") + string(os.PathListSeparator) + filepath.Dir(newer),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != newer {
+		t.Fatalf("expected bash from search path, got %s", path)
+	}
+	if version != (BashVersion{4, 4, 23}) {
+		t.Fatalf("unexpected version: %v", version)
+	}
+}
+
+func TestFindBashShell(t *testing.T) {
+	setFindBashEnv(t)
+	dir := t.TempDir()
+	shell := fakeBash(t, dir, "shell", "5.0.17")
+	t.Setenv("SHELL", shell)
+	t.Setenv("PATH", filepath.Dir(fakeBash(t, dir, "path", "5.2.0")))
+
+	path, _, err := FindBash(Requirements{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != shell {
+		t.Fatalf("expected SHELL to be used, got %s", path)
+	}
+
+	// shells other than bash are skipped
+	zsh := filepath.Join(dir, "zsh")
+	if err := os.WriteFile(zsh, []byte("#!/bin/sh\necho 5.9\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", zsh)
+	path, _, err = FindBash(Requirements{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "path", "bash") {
+		t.Fatalf("expected bash from PATH, got %s", path)
+	}
+}
+
+func TestFindBashSkipsBroken(t *testing.T) {
+	setFindBashEnv(t)
+	dir := t.TempDir()
+	// what a missing release asset downloads as
+	broken := filepath.Join(dir, "bash")
+	if err := os.WriteFile(broken, []byte("Not Found"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", broken)
+	found := fakeBash(t, dir, "path", "5.0.17")
+	t.Setenv("PATH", filepath.Dir(found))
+
+	path, _, err := FindBash(Requirements{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != found {
+		t.Fatalf("expected broken bash to be skipped, got %s", path)
+	}
+}
+
+func TestFindBashOverrideUnusable(t *testing.T) {
+	setFindBashEnv(t)
+	dir := t.TempDir()
+	t.Setenv("SHELL", fakeBash(t, dir, "shell", "5.2.15"))
+	old := fakeBash(t, dir, "old", "3.2.57")
+
+	for override, message := range map[string]string{
+		filepath.Join(dir, "missing"): "BASHER_BASH=" + filepath.Join(dir, "missing") + ": ",
+		old:                           "version 3.2.57 is older than 4.4.0",
+	} {
+		t.Setenv("BASHER_BASH", override)
+		_, _, err := FindBash(Requirements{MinVersion: "4.4"})
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("expected error for BASHER_BASH=%s, got %v", override, err)
+		}
+	}
+}
+
+func TestFindBashNoneSuitable(t *testing.T) {
+	setFindBashEnv(t)
+	t.Setenv("SHELL", fakeBash(t, t.TempDir(), "shell", "3.2.57"))
+
+	_, _, err := FindBash(Requirements{MinVersion: "99"})
+	if err == nil {
+		t.Fatal("expected error when no bash is new enough")
+	}
+	if !strings.Contains(err.Error(), "3.2.57 is older than 99.0.0") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, _, err := FindBash(Requirements{MinVersion: "latest"}); err == nil {
+		t.Fatal("expected error for invalid MinVersion")
+	}
+}
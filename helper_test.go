@@ -0,0 +1,61 @@
+package basher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// multiCallEnv is set in the environment of the test binary when tests run
+// it as the helper App, and so also for callbacks to its Go functions.
+const multiCallEnv = "BASHER_TEST_MULTICALL"
+
+// helperApp configures the helper App. Test files add the functions and
+// options their tests need in init.
+var helperApp = AppConfig{
+	Funcs:    map[string]func([]string){},
+	Handlers: map[string]Handler{},
+	Scripts:  []string{"multi.sh"},
+	Loader:   testLoader,
+	CopyEnv:  true,
+	BashPath: bashpath,
+}
+
+// TestMain runs the test binary as the helper App when it is invoked by the
+// multi-call tests, including for callbacks to its Go functions.
+func TestMain(m *testing.M) {
+	if os.Getenv(multiCallEnv) == "" {
+		os.Exit(m.Run())
+	}
+	status, err := NewApp(helperApp).Main(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(status)
+}
+
+func runMultiCall(t *testing.T, path string, args ...string) string {
+	return runMultiCallEnv(t, nil, path, args...)
+}
+
+func runMultiCallEnv(t *testing.T, env []string, path string, args ...string) string {
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(append(os.Environ(), multiCallEnv+"=1"), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	return string(out)
+}
+
+func installTestLinks(t *testing.T) string {
+	dir := t.TempDir()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runMultiCall(t, self, "--install-links", dir)
+	return dir
+}
@@ -0,0 +1,54 @@
+package basher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	memoryBashOnce sync.Once
+	memoryBashFile *os.File
+	memoryBashErr  error
+)
+
+// memoryBashPath loads the embedded bash into a sealed memfd and returns a
+// path that executes it. The path goes through /proc/<pid> rather than
+// /proc/self because it is resolved by the child during exec, after which
+// the close-on-exec descriptor is gone from the child's own table. The memfd
+// is created once and kept open for the life of the process.
+func memoryBashPath() (string, error) {
+	memoryBashOnce.Do(func() {
+		memoryBashFile, memoryBashErr = createMemoryBash()
+	})
+	if memoryBashErr != nil {
+		return "", memoryBashErr
+	}
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), memoryBashFile.Fd()), nil
+}
+
+func createMemoryBash() (*os.File, error) {
+	asset, _, err := openAsset("bash")
+	if err != nil {
+		return nil, err
+	}
+	defer asset.Close()
+	fd, err := unix.MemfdCreate("bash", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, fmt.Errorf("basher: memfd_create: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "memfd:bash")
+	if _, err := io.Copy(f, asset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	seals := unix.F_SEAL_SEAL | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("basher: sealing memfd: %w", err)
+	}
+	return f, nil
+}
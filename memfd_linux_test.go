@@ -0,0 +1,74 @@
+//go:build !basher_nobash
+// +build !basher_nobash
+
+package basher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBash(t *testing.T) {
+	home := setTestHome(t)
+	MemoryBash = true
+	defer func() { MemoryBash = false }()
+
+	path, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, "/proc/") {
+		t.Fatalf("expected a /proc path, got %s", path)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cache", "basher")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing extracted to disk, stat err=%v", err)
+	}
+
+	bash, _ := NewContext(path, false)
+	bash.Source("hello.sh", testLoader)
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+	status, err := bash.Run("main", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 || stdout.String() != "hello\n" {
+		t.Fatalf("unexpected result: status=%d stdout=%q", status, stdout.String())
+	}
+}
+
+func TestMemoryBashFallback(t *testing.T) {
+	setTestHome(t)
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ExtractDir = blocked
+	defer func() { ExtractDir = "" }()
+
+	path, err := EmbeddedBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, "/proc/") {
+		t.Fatalf("expected fallback to a /proc path, got %s", path)
+	}
+}
+
+func TestMemoryBashSealed(t *testing.T) {
+	path, err := memoryBashPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err == nil {
+		_, err = f.Write([]byte("tampered"))
+		f.Close()
+	}
+	if err == nil {
+		t.Fatal("expected writes to the sealed memfd to fail")
+	}
+}
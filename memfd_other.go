@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package basher
+
+import "errors"
+
+func memoryBashPath() (string, error) {
+	return "", errors.New("basher: running bash from memory requires Linux")
+}
@@ -0,0 +1,80 @@
+package basher
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// A Callback is a call from Bash to an exported Go function.
+type Callback struct {
+	// Name is the name the function was exported as
+	Name string
+
+	// Args are the arguments it was called with
+	Args []string
+
+	// Caller is where it was called from
+	Caller Caller
+
+	// Context runs Bash in the environment of the run that made the
+	// call, with the same variables, scripts and functions, so a Go
+	// function can call back into Bash. Changes made to it with Export,
+	// Source or ExportFunc are not seen by Bash. Runs nested deeper than
+	// its MaxDepth fail with ErrMaxDepth. It is nil if the run can't be
+	// re-entered, as when it replaced the process with Exec.
+	Context *Context
+}
+
+// A Handler handles a Callback.
+type Handler func(cb Callback)
+
+// argsHandler returns a Handler calling fn with the arguments.
+func argsHandler(fn func([]string)) Handler {
+	return func(cb Callback) { fn(cb.Args) }
+}
+
+// ExportHandler is like ExportFunc, but the function is a Handler, which is
+// given the Caller and can run Bash again through the Context of the
+// Callback.
+func (c *Context) ExportHandler(name string, h Handler) {
+	c.Lock()
+	defer c.Unlock()
+	c.funcs[name] = h
+}
+
+// Use adds middleware wrapping every function registered with ExportFunc
+// when it is called back from Bash. Middleware added first is outermost.
+func (c *Context) Use(middleware ...func(next Handler) Handler) {
+	c.Lock()
+	defer c.Unlock()
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// handler returns h wrapped in the middleware. The caller must hold the
+// lock.
+func (c *Context) handler(h Handler) Handler {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h
+}
+
+// recoverStatus is the exit status used by Recover, EX_SOFTWARE from
+// sysexits.h.
+const recoverStatus = 70
+
+// Recover is middleware that turns a panic in a Go function into a message
+// and stack trace on stderr and exit status 70, which Bash sees as the status
+// of the function.
+func Recover(next Handler) Handler {
+	return func(cb Callback) {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "basher: panic in %s called from %s: %v\n\n%s", cb.Name, cb.Caller, r, debug.Stack())
+				os.Exit(recoverStatus)
+			}
+		}()
+		next(cb)
+	}
+}
@@ -0,0 +1,68 @@
+package basher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	helperApp.Funcs["explode"] = func(args []string) {
+		panic("boom")
+	}
+	helperApp.Middleware = append(helperApp.Middleware, Recover)
+	helperApp.MultiCall = append(helperApp.MultiCall, "explode")
+}
+
+func TestUse(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	var trace []string
+	bash.ExportFunc("login", func(args []string) {
+		trace = append(trace, "login "+strings.Join(args, " "))
+	})
+	record := func(label string) func(Handler) Handler {
+		return func(next Handler) Handler {
+			return func(cb Callback) {
+				trace = append(trace, label+" "+cb.Name)
+				next(cb)
+			}
+		}
+	}
+	redact := func(next Handler) Handler {
+		return func(cb Callback) {
+			args := append([]string(nil), cb.Args...)
+			for i, arg := range args {
+				if strings.HasPrefix(arg, "--password=") {
+					args[i] = "--password=***"
+				}
+			}
+			next(Callback{Name: cb.Name, Args: args})
+		}
+	}
+	bash.Use(record("outer"), record("inner"))
+	bash.Use(redact)
+
+	t.Setenv(callbackVar, "nonce")
+	bash.HandleFuncs([]string{"app", "nonce", "login", "--password=hunter2"})
+	expected := "outer login|inner login|login --password=***"
+	if got := strings.Join(trace, "|"); got != expected {
+		t.Fatalf("unexpected trace: %q", got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := installTestLinks(t)
+	cmd := exec.Command(filepath.Join(dir, "explode"))
+	cmd.Env = append(os.Environ(), multiCallEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != recoverStatus {
+		t.Fatalf("expected exit status %d, got %v: %s", recoverStatus, err, out)
+	}
+	if !strings.Contains(string(out), "basher: panic in explode called from top level: boom") ||
+		!strings.Contains(string(out), "goroutine ") {
+		t.Fatalf("expected panic and stack trace, got: %s", out)
+	}
+}
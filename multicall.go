@@ -0,0 +1,51 @@
+package basher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kardianos/osext"
+)
+
+// multiCallName returns the name of the function args[0] selects from the
+// MultiCall names, if any.
+func (a *App) multiCallName(args []string) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	name := filepath.Base(args[0])
+	for _, candidate := range a.config.MultiCall {
+		if candidate == name {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// InstallLinks creates a symlink to the running executable in dir for each of
+// the MultiCall names, so each name runs its function. Existing symlinks are
+// replaced, but other files in the way are left alone and reported.
+func (a *App) InstallLinks(dir string) error {
+	executable, err := osext.Executable()
+	if err != nil {
+		return err
+	}
+	for _, name := range a.config.MultiCall {
+		link := filepath.Join(dir, name)
+		if target, err := os.Readlink(link); err == nil {
+			if target == executable {
+				continue
+			}
+			if err := os.Remove(link); err != nil {
+				return err
+			}
+		} else if _, err := os.Lstat(link); err == nil {
+			return fmt.Errorf("basher: installing link %s: %w", link, os.ErrExist)
+		}
+		if err := os.Symlink(executable, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
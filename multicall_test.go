@@ -0,0 +1,80 @@
+package basher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	helperApp.Funcs["shout"] = func(args []string) {
+		fmt.Println(strings.ToUpper(strings.Join(args, " ")))
+	}
+	helperApp.MultiCall = append(helperApp.MultiCall, "greet", "shout")
+}
+
+func TestMultiCall(t *testing.T) {
+	dir := installTestLinks(t)
+
+	if out := runMultiCall(t, filepath.Join(dir, "greet"), "world"); out != "hello world\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if out := runMultiCall(t, filepath.Join(dir, "shout"), "hey", "you"); out != "HEY YOU\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	// other names run main
+	other := filepath.Join(dir, "other")
+	self, _ := os.Executable()
+	if err := os.Symlink(self, other); err != nil {
+		t.Fatal(err)
+	}
+	if out := runMultiCall(t, other, "greet"); out != "main greet\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestInstallLinks(t *testing.T) {
+	dir := t.TempDir()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp(AppConfig{MultiCall: []string{"greet", "shout"}})
+
+	// a stale symlink is replaced
+	if err := os.Symlink("/nonexistent", filepath.Join(dir, "greet")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := app.InstallLinks(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"greet", "shout"} {
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != self {
+			t.Fatalf("unexpected target for %s: %s", name, target)
+		}
+	}
+
+	// regular files are not replaced
+	if err := os.Remove(filepath.Join(dir, "shout")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shout"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.InstallLinks(dir); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "shout")); string(data) != "keep" {
+		t.Fatalf("file was replaced: %q", data)
+	}
+}
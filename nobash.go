@@ -0,0 +1,57 @@
+//go:build basher_nobash
+// +build basher_nobash
+
+package basher
+
+import (
+	"io"
+	"os"
+)
+
+// Built with basher_nobash, there is no embedded bash to describe. These
+// stand in for the values generated alongside the real assets.
+const (
+	bashStaticVersion = "none"
+	bashSHA256        = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+var bashGzip []byte
+
+func openAsset(name string) (io.ReadCloser, os.FileInfo, error) {
+	return nil, nil, ErrNoEmbeddedBash
+}
+
+// Asset always returns ErrNoEmbeddedBash when built with basher_nobash.
+func Asset(name string) ([]byte, error) {
+	return nil, ErrNoEmbeddedBash
+}
+
+// MustAsset always panics when built with basher_nobash.
+func MustAsset(name string) []byte {
+	panic(ErrNoEmbeddedBash)
+}
+
+// AssetInfo always returns ErrNoEmbeddedBash when built with basher_nobash.
+func AssetInfo(name string) (os.FileInfo, error) {
+	return nil, ErrNoEmbeddedBash
+}
+
+// AssetNames returns no names when built with basher_nobash.
+func AssetNames() []string {
+	return []string{}
+}
+
+// AssetDir always returns ErrNoEmbeddedBash when built with basher_nobash.
+func AssetDir(name string) ([]string, error) {
+	return nil, ErrNoEmbeddedBash
+}
+
+// RestoreAsset always returns ErrNoEmbeddedBash when built with basher_nobash.
+func RestoreAsset(dir, name string) error {
+	return ErrNoEmbeddedBash
+}
+
+// RestoreAssets always returns ErrNoEmbeddedBash when built with basher_nobash.
+func RestoreAssets(dir, name string) error {
+	return ErrNoEmbeddedBash
+}
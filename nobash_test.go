@@ -0,0 +1,32 @@
+//go:build basher_nobash
+// +build basher_nobash
+
+package basher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNoBashAsset(t *testing.T) {
+	if _, err := Asset("bash"); !errors.Is(err, ErrNoEmbeddedBash) {
+		t.Fatalf("expected ErrNoEmbeddedBash from Asset, got %v", err)
+	}
+	if _, err := AssetInfo("bash"); !errors.Is(err, ErrNoEmbeddedBash) {
+		t.Fatalf("expected ErrNoEmbeddedBash from AssetInfo, got %v", err)
+	}
+}
+
+func TestNoBashEmbeddedBashPath(t *testing.T) {
+	MemoryBash = true
+	defer func() { MemoryBash = false }()
+	if _, err := EmbeddedBashPath(); !errors.Is(err, ErrNoEmbeddedBash) {
+		t.Fatalf("expected ErrNoEmbeddedBash, got %v", err)
+	}
+}
+
+func TestNoBashEmbeddedBashInfo(t *testing.T) {
+	if _, err := EmbeddedBashInfo(); !errors.Is(err, ErrNoEmbeddedBash) {
+		t.Fatalf("expected ErrNoEmbeddedBash, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package basher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DefaultMaxDepth is the limit on nested callbacks used when
+// Context.MaxDepth is zero.
+const DefaultMaxDepth = 8
+
+// ErrMaxDepth is returned when running Bash through Callback.Context would
+// nest callbacks deeper than the Context allows.
+var ErrMaxDepth = errors.New("basher: maximum callback depth exceeded")
+
+// The variables letting a callback run Bash again, set only for the command
+// calling back into the executable, like callbackVar.
+const (
+	reentryEnvfileVar = "BASHER_ENVFILE"
+	reentryBashVar    = "BASHER_REENTRY_BASH"
+	reentryDepthVar   = "BASHER_DEPTH"
+)
+
+// reentryVars returns the assignments passing what a callback needs to run
+// Bash again.
+func reentryVars() string {
+	return fmt.Sprintf("%s=$__basher_envfile %s=$BASH %s=${__basher_depth-0}",
+		reentryEnvfileVar, reentryBashVar, reentryDepthVar)
+}
+
+// reentrant returns a Context for a callback to run Bash in the environment
+// of the run that called it, read from the variables set by reentryVars,
+// which are removed. It returns nil if that environment can't be read, as
+// when the run replaced the process with Exec.
+func (c *Context) reentrant() *Context {
+	get := func(name string) string {
+		value := os.Getenv(name)
+		os.Unsetenv(name)
+		return value
+	}
+	envfile := get(reentryEnvfileVar)
+	bashPath := get(reentryBashVar)
+	depth, err := strconv.Atoi(get(reentryDepthVar))
+	if envfile == "" || bashPath == "" || err != nil {
+		return nil
+	}
+	if _, err := os.Stat(envfile); err != nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	return &Context{
+		Debug:     c.Debug,
+		BashPath:  bashPath,
+		SelfPath:  c.SelfPath,
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+		MaxDepth:  c.MaxDepth,
+		funcs:     c.funcs,
+		specs:     c.specs,
+		nonce:     c.nonce,
+		exePath:   c.exePath,
+		exeID:     c.exeID,
+		selfFile:  c.selfFile,
+		depth:     depth + 1,
+		inherited: envfile,
+	}
+}
+
+// checkDepth returns ErrMaxDepth if the Context is nested too deeply to run
+// Bash.
+func (c *Context) checkDepth() error {
+	max := c.MaxDepth
+	if max == 0 {
+		max = DefaultMaxDepth
+	}
+	if c.depth > max {
+		return fmt.Errorf("%w (%d)", ErrMaxDepth, max)
+	}
+	return nil
+}
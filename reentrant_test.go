@@ -0,0 +1,128 @@
+package basher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	helperApp.Handlers["retry"] = func(cb Callback) {
+		status := 0
+		for attempt := 0; attempt < 3; attempt++ {
+			status, _ = cb.Context.Run(cb.Args[0], cb.Args[1:])
+			if status == 0 {
+				break
+			}
+		}
+		os.Exit(status)
+	}
+	helperApp.Handlers["recurse"] = func(cb Callback) {
+		fmt.Fprintln(os.Stderr, "depth", cb.Context.depth)
+		status, err := cb.Context.Run("recurse", nil)
+		if errors.Is(err, ErrMaxDepth) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(3)
+		}
+		os.Exit(status)
+	}
+	helperApp.MultiCall = append(helperApp.MultiCall, "retrydemo", "recurse")
+}
+
+func TestCallbackContext(t *testing.T) {
+	dir := installTestLinks(t)
+	counter := filepath.Join(t.TempDir(), "counter")
+	env := []string{"COUNTER=" + counter}
+
+	// retry runs flaky again from Go, with its arguments, until it succeeds
+	out := runMultiCallEnv(t, env, filepath.Join(dir, "retrydemo"), "x")
+	if out != "attempt 1 x\nattempt 2 x\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestCallbackContextMaxDepth(t *testing.T) {
+	dir := installTestLinks(t)
+	cmd := exec.Command(filepath.Join(dir, "recurse"))
+	cmd.Env = append(os.Environ(), multiCallEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit status 3, got %v: %s", err, out)
+	}
+	if !strings.Contains(string(out), ErrMaxDepth.Error()+" (8)") {
+		t.Fatalf("expected depth error, got: %s", out)
+	}
+	n := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "depth ") {
+			n++
+		}
+	}
+	if n != DefaultMaxDepth+1 {
+		t.Fatalf("expected %d nested callbacks, got %d: %s", DefaultMaxDepth+1, n, out)
+	}
+}
+
+func TestCheckDepth(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.depth = 3
+	bash.MaxDepth = 2
+	if _, err := bash.Run("true", nil); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("expected ErrMaxDepth, got %v", err)
+	}
+	bash.MaxDepth = 3
+	if _, err := bash.Run("true", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallbackContextUnavailable(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	var got Callback
+	bash.ExportHandler("myfunc", func(cb Callback) { got = cb })
+
+	t.Setenv(callbackVar, "nonce")
+	t.Setenv(reentryEnvfileVar, "/dev/fd/999")
+	t.Setenv(reentryBashVar, bashpath)
+	t.Setenv(reentryDepthVar, "0")
+	if handled, err := bash.HandleCallback([]string{"app", "nonce", "myfunc"}); !handled || err != nil {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+	if got.Context != nil {
+		t.Fatal("expected no Context for an environment that can't be read")
+	}
+}
+
+func TestAppCallbackKeepsBashOverride(t *testing.T) {
+	envfile := filepath.Join(t.TempDir(), "envfile")
+	if err := os.WriteFile(envfile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BASHER_BASH", "/nonexistent/bash")
+	t.Setenv(callbackVar, "nonce")
+	t.Setenv(reentryEnvfileVar, envfile)
+	t.Setenv(reentryBashVar, bashpath)
+	t.Setenv(reentryDepthVar, "0")
+	var override, reentry string
+	app := NewApp(AppConfig{
+		Handlers: map[string]Handler{
+			"myfunc": func(cb Callback) {
+				override = os.Getenv("BASHER_BASH")
+				reentry = cb.Context.BashPath
+			},
+		},
+		Args: []string{"app", "nonce", "myfunc"},
+	})
+	if status, err := app.Main(context.Background()); status != 0 || err != nil {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	if override != "/nonexistent/bash" || reentry != bashpath {
+		t.Fatalf("unexpected bash: override %q, reentry %q", override, reentry)
+	}
+}
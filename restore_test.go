@@ -0,0 +1,292 @@
+//go:build !basher_nobash
+// +build !basher_nobash
+
+package basher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestoreBashAtomicallyFreshDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	bashPath := filepath.Join(dir, "bash")
+	got, err := os.ReadFile(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Asset("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha256.Sum256(got) != sha256.Sum256(want) {
+		t.Fatalf("extracted bash bytes differ from embedded asset (got %d bytes, want %d)", len(got), len(want))
+	}
+
+	info, err := os.Stat(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Fatalf("extracted bash is not executable: mode=%v", info.Mode())
+	}
+}
+
+func TestRestoreBashAtomicallyNoOpWhenVerified(t *testing.T) {
+	dir := t.TempDir()
+	bashPath := filepath.Join(dir, "bash")
+	sentinel := []byte("sentinel-not-bash")
+	if err := os.WriteFile(bashPath, sentinel, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a stamp matching the file's size and mtime is trusted without hashing
+	info, err := os.Stat(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeBashStamp(dir, info)
+
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, sentinel) {
+		t.Fatalf("verified bash file was overwritten; got %q want %q", got, sentinel)
+	}
+}
+
+func TestRestoreBashAtomicallyRecoversCorruption(t *testing.T) {
+	want, err := Asset("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corruptions := map[string]func(string) error{
+		"truncated": func(path string) error {
+			return os.Truncate(path, int64(len(want)/2))
+		},
+		"flipped": func(path string) error {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.WriteAt([]byte{^want[0]}, 0)
+			return err
+		},
+		"replaced": func(path string) error {
+			return os.WriteFile(path, []byte("#!/bin/sh\necho tampered\n"), 0755)
+		},
+	}
+	for name, corrupt := range corruptions {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := restoreBashAtomically(dir); err != nil {
+				t.Fatal(err)
+			}
+			bashPath := filepath.Join(dir, "bash")
+			if err := corrupt(bashPath); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := restoreBashAtomically(dir); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(bashPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sha256.Sum256(got) != sha256.Sum256(want) {
+				t.Fatal("corrupted bash was not re-extracted")
+			}
+			if !verifyBash(dir) {
+				t.Fatal("re-extracted bash does not verify")
+			}
+		})
+	}
+}
+
+func TestRestoreBashAtomicallyRewritesStaleStamp(t *testing.T) {
+	dir := t.TempDir()
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+	stampPath := filepath.Join(dir, bashStampName)
+	if err := os.Remove(stampPath); err != nil {
+		t.Fatal(err)
+	}
+
+	bashPath := filepath.Join(dir, "bash")
+	before, err := os.Stat(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Fatal("intact bash was re-extracted instead of re-stamped")
+	}
+	stamp, err := os.ReadFile(stampPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stamp) != bashStamp(after) {
+		t.Fatalf("unexpected stamp: %q", stamp)
+	}
+}
+
+func TestRestoreBashAtomicallyNoTempLeftover(t *testing.T) {
+	dir := t.TempDir()
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "bash.tmp.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("expected no bash.tmp.* leftovers, got %v", matches)
+	}
+}
+
+func TestRestoreBashAtomicallySweepsStaleTemp(t *testing.T) {
+	dir := t.TempDir()
+
+	stalePath := filepath.Join(dir, "bash.tmp.stale")
+	if err := os.WriteFile(stalePath, []byte("orphan"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stalePath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	freshPath := filepath.Join(dir, "bash.tmp.fresh")
+	if err := os.WriteFile(freshPath, []byte("inflight"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Fatalf("expected stale temp to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(freshPath); err != nil {
+		t.Fatalf("expected fresh temp to be left alone, got err=%v", err)
+	}
+}
+
+func TestRestoreBashAtomicallyConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	const workers = 16
+	start := make(chan struct{})
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			<-start
+			errs <- restoreBashAtomically(dir)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent restoreBashAtomically failed: %v", err)
+		}
+	}
+
+	bashPath := filepath.Join(dir, "bash")
+	got, err := os.ReadFile(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Asset("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha256.Sum256(got) != sha256.Sum256(want) {
+		t.Fatalf("torn write: extracted bash bytes differ from embedded asset (got %d bytes, want %d)", len(got), len(want))
+	}
+
+	info, err := os.Stat(bashPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Fatalf("extracted bash is not executable: mode=%v", info.Mode())
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "bash.tmp.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("expected no bash.tmp.* leftovers after concurrent run, got %v", matches)
+	}
+}
+
+func TestRestoreBashAtomicallyStreams(t *testing.T) {
+	dir := t.TempDir()
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := restoreBashAtomically(dir); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	// buffering the decompressed asset would allocate at least its size
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated >= bashSize/2 {
+		t.Fatalf("extraction allocated %d bytes for a %d byte asset", allocated, bashSize)
+	}
+}
+
+func TestAssetMatchesInfo(t *testing.T) {
+	data, err := Asset("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := AssetInfo("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != info.Size() {
+		t.Fatalf("asset is %d bytes, AssetInfo says %d", len(data), info.Size())
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != bashSHA256 {
+		t.Fatal("asset does not match bashSHA256")
+	}
+	if info.Mode() != 0755 {
+		t.Fatalf("unexpected mode: %v", info.Mode())
+	}
+	if _, err := Asset("missing"); err == nil {
+		t.Fatal("expected error for unknown asset")
+	}
+}
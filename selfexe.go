@@ -0,0 +1,49 @@
+package basher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrExecutableChanged is returned by HandleCallback when the executable
+// called back into is not the one running the Context that called it, as
+// happens when the binary is replaced during an upgrade.
+var ErrExecutableChanged = errors.New("basher: executable changed since the program started")
+
+// selfIDVar is set alongside callbackVar to the identity of the executable
+// that exported the function.
+const selfIDVar = "BASHER_SELF_ID"
+
+// fileID identifies the file at path by device, inode, size and modification
+// time, which together change when a binary is replaced.
+func fileID(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("basher: no file identity for %s", path)
+	}
+	return fmt.Sprintf("%d:%d:%d:%d", st.Dev, st.Ino, info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// checkSelfID returns ErrExecutableChanged if the running executable is not
+// the one identified by the callback.
+func checkSelfID() error {
+	want := os.Getenv(selfIDVar)
+	os.Unsetenv(selfIDVar)
+	if want == "" {
+		return nil
+	}
+	have, err := selfID()
+	if err != nil {
+		return err
+	}
+	if have != want {
+		return fmt.Errorf("%w; run it again to use the new version", ErrExecutableChanged)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package basher
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// selfID identifies the running executable. /proc/self/exe refers to it even
+// after its path has been replaced or removed.
+func selfID() (string, error) {
+	return fileID("/proc/self/exe")
+}
+
+// PinExecutable makes callbacks from Bash run the very executable that is
+// running now, even if the file at SelfPath is replaced while Bash runs, as a
+// package manager does during an upgrade. It keeps a descriptor open to the
+// executable and points SelfPath at it through /proc/<pid>/fd. On other
+// platforms it does nothing, and a replaced executable is instead refused by
+// HandleCallback with ErrExecutableChanged.
+func (c *Context) PinExecutable() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.selfFile != nil {
+		return nil
+	}
+	f, err := os.Open("/proc/self/exe")
+	if err != nil {
+		return err
+	}
+	c.selfFile = f
+	c.SelfPath = fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd())
+	c.exePath = c.SelfPath
+	return nil
+}
+
+// inheritPinned lets the descriptor from PinExecutable survive Exec, so the
+// /proc/<pid>/fd path stays valid in the Bash that replaces the process. The
+// caller must hold the lock.
+func (c *Context) inheritPinned() error {
+	if c.selfFile == nil {
+		return nil
+	}
+	_, err := unix.FcntlInt(c.selfFile.Fd(), unix.F_SETFD, 0)
+	return err
+}
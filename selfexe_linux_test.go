@@ -0,0 +1,91 @@
+package basher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pinEnv makes the helper App pin its executable.
+const pinEnv = "BASHER_TEST_PIN"
+
+func init() {
+	helperApp.PinExecutable = os.Getenv(pinEnv) != ""
+	helperApp.MultiCall = append(helperApp.MultiCall, "upgrade")
+}
+
+func TestPinExecutable(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	if err := bash.PinExecutable(); err != nil {
+		t.Fatal(err)
+	}
+	defer bash.selfFile.Close()
+	if !strings.HasPrefix(bash.SelfPath, fmt.Sprintf("/proc/%d/fd/", os.Getpid())) {
+		t.Fatalf("unexpected SelfPath: %s", bash.SelfPath)
+	}
+	pinned, err := fileID(bash.SelfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned != bash.exeID {
+		t.Fatalf("pinned %s, running %s", pinned, bash.exeID)
+	}
+}
+
+// copyTestBinary copies the test binary to dir under name, so it can be
+// replaced while running.
+func copyTestBinary(t *testing.T, dir string, name string) string {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	path := filepath.Join(dir, name)
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runUpgrade(t *testing.T, pin bool) (string, error) {
+	path := copyTestBinary(t, t.TempDir(), "upgrade")
+	cmd := exec.Command(path, "hey")
+	cmd.Env = append(os.Environ(), multiCallEnv+"=1", "UPGRADE="+path)
+	if pin {
+		cmd.Env = append(cmd.Env, pinEnv+"=1")
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestCallbackDuringUpgrade(t *testing.T) {
+	// without pinning, the replaced binary refuses the callback
+	out, err := runUpgrade(t, false)
+	if err == nil || !strings.Contains(out, ErrExecutableChanged.Error()) {
+		t.Fatalf("expected executable changed error, got %v: %s", err, out)
+	}
+
+	// pinned, the callback runs the binary that was replaced
+	out, err = runUpgrade(t, true)
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if out != "HEY\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package basher
+
+import "github.com/kardianos/osext"
+
+// selfID identifies the running executable.
+func selfID() (string, error) {
+	executable, err := osext.Executable()
+	if err != nil {
+		return "", err
+	}
+	return fileID(executable)
+}
+
+// PinExecutable makes callbacks from Bash run the very executable that is
+// running now on Linux. On other platforms it does nothing, and a replaced
+// executable is instead refused by HandleCallback with ErrExecutableChanged.
+func (c *Context) PinExecutable() error {
+	return nil
+}
+
+// inheritPinned does nothing, as nothing is pinned on this platform.
+func (c *Context) inheritPinned() error {
+	return nil
+}
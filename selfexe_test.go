@@ -0,0 +1,61 @@
+package basher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleCallbackExecutableChanged(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	called := false
+	bash.ExportFunc("myfunc", func([]string) { called = true })
+
+	t.Setenv(callbackVar, "nonce")
+	t.Setenv(selfIDVar, "0:0:0:0")
+	handled, err := bash.HandleCallback([]string{"app", "nonce", "myfunc"})
+	if !handled || !errors.Is(err, ErrExecutableChanged) {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+	if called {
+		t.Fatal("function was called by a different executable")
+	}
+
+	id, err := selfID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(callbackVar, "nonce")
+	t.Setenv(selfIDVar, id)
+	if handled, err := bash.HandleCallback([]string{"app", "nonce", "myfunc"}); !handled || err != nil {
+		t.Fatalf("unexpected result: %v, %v", handled, err)
+	}
+	if !called {
+		t.Fatal("function was not called")
+	}
+}
+
+func TestCallbackCarriesSelfID(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	if bash.exeID == "" {
+		t.Fatal("expected the executable to be identified")
+	}
+	if cb := bash.callback("myfunc", 1); !containsWord(cb, selfIDVar+"="+bash.exeID) {
+		t.Fatalf("expected identity in callback: %s", cb)
+	}
+
+	// another executable handling callbacks is not checked
+	bash.SelfPath = "/bin/echo"
+	if cb := bash.callback("myfunc", 1); containsWord(cb, selfIDVar+"="+bash.exeID) {
+		t.Fatalf("unexpected identity in callback: %s", cb)
+	}
+}
+
+func containsWord(s string, word string) bool {
+	for _, w := range strings.Fields(s) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
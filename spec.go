@@ -0,0 +1,134 @@
+package basher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Spec describes an exported Go function for help and documentation.
+type Spec struct {
+	// Short is a one line description
+	Short string
+
+	// Usage describes the arguments after the function name, such as
+	// "[--force] <target>"
+	Usage string
+
+	// Args describes the positional arguments
+	Args []Arg
+
+	// Flags describes the flags accepted
+	Flags []Flag
+}
+
+// An Arg is a positional argument described by a Spec.
+type Arg struct {
+	Name        string
+	Description string
+}
+
+// A Flag is a flag described by a Spec. Name includes the leading dashes.
+type Flag struct {
+	Name        string
+	Description string
+}
+
+// Help formats the help for the function called name, as printed by
+// `name --help`.
+func (s Spec) Help(name string) string {
+	var b bytes.Buffer
+	usage := name
+	if s.Usage != "" {
+		usage += " " + s.Usage
+	}
+	fmt.Fprintf(&b, "Usage: %s\n", usage)
+	if s.Short != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.Short)
+	}
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	if len(s.Args) > 0 {
+		fmt.Fprintf(tw, "\nArguments:\n")
+		for _, arg := range s.Args {
+			fmt.Fprintf(tw, "  %s\t%s\n", arg.Name, arg.Description)
+		}
+	}
+	if len(s.Flags) > 0 {
+		fmt.Fprintf(tw, "\nFlags:\n")
+		for _, flag := range s.Flags {
+			fmt.Fprintf(tw, "  %s\t%s\n", flag.Name, flag.Description)
+		}
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// ExportFuncWithSpec is like ExportFunc, but the Bash function prints the help
+// from spec when its only argument is --help instead of calling fn.
+func (c *Context) ExportFuncWithSpec(name string, spec Spec, fn func([]string)) {
+	c.Lock()
+	defer c.Unlock()
+	c.funcs[name] = argsHandler(fn)
+	c.specs[name] = spec
+}
+
+// DescribeFunc sets the Spec for a Bash function defined by a sourced script,
+// which is used when listing subcommands and completing their flags.
+func (c *Context) DescribeFunc(name string, spec Spec) {
+	c.Lock()
+	defer c.Unlock()
+	c.specs[name] = spec
+}
+
+// Specs returns the Spec of every function registered with ExportFunc,
+// ExportFuncWithSpec or DescribeFunc, keyed by name. Functions exported
+// without one have a zero Spec.
+func (c *Context) Specs() map[string]Spec {
+	c.Lock()
+	defer c.Unlock()
+	specs := make(map[string]Spec, len(c.funcs)+len(c.specs))
+	for name := range c.funcs {
+		specs[name] = c.specs[name]
+	}
+	for name, spec := range c.specs {
+		specs[name] = spec
+	}
+	return specs
+}
+
+// writeFuncs writes the Bash function calling back into the executable for
+// each exported Go function, followed by basher_help, which lists them all.
+// The caller must hold the lock.
+func (c *Context) writeFuncs(w io.Writer) {
+	names := make([]string, 0, len(c.funcs))
+	for name := range c.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	var list strings.Builder
+	for _, name := range names {
+		spec, ok := c.specs[name]
+		if ok {
+			fmt.Fprintf(w, "%s() { if [[ $# -eq 1 && $1 == --help ]]; then printf %%s %s; return 0; fi; %s; }\n",
+				name, shellQuote(spec.Help(name)), c.callback(name, 1))
+		} else {
+			fmt.Fprintf(w, "%s() { %s; }\n", name, c.callback(name, 1))
+		}
+		if spec.Short == "" {
+			fmt.Fprintf(&list, "%s\n", name)
+		} else {
+			fmt.Fprintf(&list, "%-*s  %s\n", width, name, spec.Short)
+		}
+	}
+	fmt.Fprintf(w, "basher_help() { printf %%s %s; }\n", shellQuote(list.String()))
+}
@@ -0,0 +1,82 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+var deploySpec = Spec{
+	Short: "Deploy the application",
+	Usage: "[--force] <target>",
+	Args:  []Arg{{Name: "target", Description: "environment to deploy to"}},
+	Flags: []Flag{
+		{Name: "--force", Description: "skip checks"},
+		{Name: "--dry-run", Description: "only print the plan"},
+	},
+}
+
+func TestSpecHelp(t *testing.T) {
+	expected := `Usage: deploy [--force] <target>
+
+Deploy the application
+
+Arguments:
+  target  environment to deploy to
+
+Flags:
+  --force    skip checks
+  --dry-run  only print the plan
+`
+	if help := deploySpec.Help("deploy"); help != expected {
+		t.Fatalf("unexpected help:\n%s", help)
+	}
+	if help := (Spec{}).Help("plain"); help != "Usage: plain\n" {
+		t.Fatalf("unexpected help: %q", help)
+	}
+}
+
+func TestExportFuncWithSpecHelp(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.ExportFuncWithSpec("deploy", deploySpec, func([]string) {})
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	status, err := bash.Run("deploy", []string{"--help"})
+	if err != nil || status != 0 {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	if stdout.String() != deploySpec.Help("deploy") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestBasherHelp(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.ExportFuncWithSpec("deploy", deploySpec, func([]string) {})
+	bash.ExportFuncWithSpec("status", Spec{Short: "Show status"}, func([]string) {})
+	bash.ExportFunc("reverse", func([]string) {})
+
+	out, err := bash.Call(context.Background(), "basher_help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "deploy   Deploy the application\nreverse\nstatus   Show status"
+	if out != expected {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSpecs(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.ExportFuncWithSpec("deploy", deploySpec, func([]string) {})
+	bash.ExportFunc("reverse", func([]string) {})
+
+	specs := bash.Specs()
+	if len(specs) != 2 {
+		t.Fatalf("unexpected specs: %v", specs)
+	}
+	if specs["deploy"].Short != deploySpec.Short || specs["reverse"].Short != "" {
+		t.Fatalf("unexpected specs: %v", specs)
+	}
+}
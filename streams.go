@@ -0,0 +1,209 @@
+package basher
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A stream is an io.Reader or io.Writer exported to Bash as a file path.
+type stream struct {
+	name   string
+	reader *readerPump
+	writer io.Writer
+}
+
+// A readerPump reads an exported io.Reader from a single goroutine, started
+// by the first run using it, and holds on to what Bash has not consumed so
+// that the next run sees it.
+type readerPump struct {
+	r     io.Reader
+	start sync.Once
+
+	// chunks carries the data read from r, and is closed once r is
+	// exhausted or fails
+	chunks chan []byte
+
+	// mu is held by a run for as long as it feeds Bash from the pump, so
+	// runs using it at the same time take turns
+	mu      sync.Mutex
+	pending []byte
+}
+
+// pump reads r until it is exhausted or fails.
+func (p *readerPump) pump() {
+	defer close(p.chunks)
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := p.r.Read(buf)
+		if n > 0 {
+			p.chunks <- buf[:n]
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// feed writes the data from the pump to w until stop is closed, closing w
+// once r is exhausted so that Bash sees the end of the data. Whatever could
+// not be written is kept in pending. The caller must hold mu.
+func (p *readerPump) feed(w *os.File, stop <-chan struct{}) {
+	for {
+		if len(p.pending) == 0 {
+			select {
+			case chunk, ok := <-p.chunks:
+				if !ok {
+					w.Close()
+					return
+				}
+				p.pending = chunk
+			case <-stop:
+				return
+			}
+		}
+		n, err := w.Write(p.pending)
+		p.pending = p.pending[n:]
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ExportReader makes r readable from Bash as the file named by $name. During
+// each run, data from r is fed into a pipe whose path, such as /dev/fd/3, is
+// exported as name. Data that Bash did not read, including what was left in
+// the pipe, is kept for the next run, so runs see r as one continuous stream.
+// Only a single goroutine ever reads r, and one whose Read never returns is
+// left behind.
+func (c *Context) ExportReader(name string, r io.Reader) {
+	c.exportStream(stream{name: name, reader: &readerPump{r: r, chunks: make(chan []byte)}})
+}
+
+// ExportWriter makes w writable from Bash as the file named by $name. During
+// each run, everything Bash writes to the path exported as name is copied to
+// w. Runs do not return until every process holding the path open has
+// closed it.
+func (c *Context) ExportWriter(name string, w io.Writer) {
+	c.exportStream(stream{name: name, writer: w})
+}
+
+func (c *Context) exportStream(s stream) {
+	c.Lock()
+	defer c.Unlock()
+	for i := range c.streams {
+		if c.streams[i].name == s.name {
+			c.streams[i] = s
+			return
+		}
+	}
+	c.streams = append(c.streams, s)
+}
+
+// wireStreams passes a pipe to inv for each exported stream and starts
+// copying data through them. It returns the environment variables naming the
+// pipes and a function that tears them down once Bash has exited, returning
+// the first error from copying into an exported io.Writer. The teardown
+// function may be called more than once.
+func (c *Context) wireStreams(inv *invocation) ([]string, func() error, error) {
+	var (
+		vars     []string
+		children []*os.File
+		feeds    []func() // stop feeding exported readers
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		copyErr  error
+		once     sync.Once
+	)
+	finish := func() error {
+		once.Do(func() {
+			// Bash is gone, so whatever is left in the pipes from exported
+			// readers is taken back before they are closed.
+			for _, stop := range feeds {
+				stop()
+			}
+			for _, f := range children {
+				f.Close()
+			}
+			wg.Wait()
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		return copyErr
+	}
+
+	for _, s := range c.streams {
+		r, w, err := os.Pipe()
+		if err != nil {
+			finish()
+			return nil, nil, err
+		}
+		if p := s.reader; p != nil {
+			// r is closed once Bash starts, so a copy is kept to take
+			// back what Bash leaves in the pipe
+			unread, err := dupFile(r)
+			if err != nil {
+				r.Close()
+				w.Close()
+				finish()
+				return nil, nil, err
+			}
+			children = append(children, unread)
+			fd := inv.addFile(r, false)
+			vars = append(vars, s.name+"=/dev/fd/"+strconv.Itoa(fd))
+			p.start.Do(func() { go p.pump() })
+			stop := make(chan struct{})
+			fed := make(chan struct{})
+			go func() {
+				defer close(fed)
+				p.mu.Lock()
+				p.feed(w, stop)
+			}()
+			feeds = append(feeds, func() {
+				close(stop)
+				// unblocks a write to a pipe Bash stopped reading
+				w.SetWriteDeadline(time.Now())
+				<-fed
+				w.Close()
+				left, _ := io.ReadAll(unread)
+				p.pending = append(left, p.pending...)
+				p.mu.Unlock()
+			})
+			continue
+		}
+		children = append(children, w)
+		fd := inv.addFile(w, false)
+		vars = append(vars, s.name+"=/dev/fd/"+strconv.Itoa(fd))
+		wg.Add(1)
+		go func(dst io.Writer) {
+			defer wg.Done()
+			defer r.Close()
+			if _, err := io.Copy(dst, r); err != nil {
+				mu.Lock()
+				if copyErr == nil {
+					copyErr = err
+				}
+				mu.Unlock()
+			}
+		}(s.writer)
+	}
+	return vars, finish, nil
+}
+
+// dupFile returns a copy of f with its own descriptor, which is not inherited
+// by child processes.
+func dupFile(f *os.File) (*os.File, error) {
+	syscall.ForkLock.RLock()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err == nil {
+		syscall.CloseOnExec(fd)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, os.NewSyscallError("dup", err)
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
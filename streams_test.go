@@ -0,0 +1,129 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExportReaderWriter(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	var out bytes.Buffer
+	bash.ExportReader("INPUT", strings.NewReader("hello streams\n"))
+	bash.ExportWriter("OUTPUT", &out)
+
+	if _, err := bash.Call(context.Background(), "upper"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "HELLO STREAMS\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestExportReaderLargeUnread(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	// far larger than a pipe buffer, so the copy blocks until torn down
+	bash.ExportReader("INPUT", bytes.NewReader(make([]byte, 1<<20)))
+
+	if _, err := bash.Call(context.Background(), "ignore"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportReaderAcrossRuns(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	data := strings.Repeat("0123456789", 20000)
+	bash.ExportReader("INPUT", strings.NewReader(data))
+
+	for _, want := range []string{data[:10], data[10:15]} {
+		out, err := bash.Call(context.Background(), "take", strconv.Itoa(len(want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != want {
+			t.Fatalf("unexpected data: %q", out)
+		}
+	}
+	out, err := bash.Call(context.Background(), "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != strconv.Itoa(len(data)-15) {
+		t.Fatalf("expected the rest of the data, got %s bytes", out)
+	}
+	// once r is exhausted, later runs see an empty file
+	if out, err := bash.Call(context.Background(), "count"); err != nil || out != "0" {
+		t.Fatalf("unexpected result: %q, %v", out, err)
+	}
+}
+
+func TestExportReaderConcurrentRuns(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	bash.ExportReader("INPUT", strings.NewReader("aaaaabbbbbcccccddddd"))
+
+	outs := make(chan string, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := bash.Call(context.Background(), "take", "5")
+			if err != nil {
+				t.Error(err)
+			}
+			outs <- out
+		}()
+	}
+	wg.Wait()
+	close(outs)
+	got := make([]string, 0)
+	for out := range outs {
+		got = append(got, out)
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != "aaaaa bbbbb ccccc ddddd" {
+		t.Fatalf("runs did not take turns reading: %q", got)
+	}
+}
+
+func TestExportWriterMultipleOpens(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	var out bytes.Buffer
+	bash.ExportReader("INPUT", strings.NewReader("first\n"))
+	bash.ExportWriter("OUTPUT", &out)
+
+	if _, err := bash.Call(context.Background(), "both"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "first\nextra\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestExportWriterError(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("streams.sh", testLoader)
+	bash.ExportReader("INPUT", strings.NewReader("data\n"))
+	bash.ExportWriter("OUTPUT", failingWriter{})
+
+	_, err := bash.Call(context.Background(), "upper")
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected writer error, got %v", err)
+	}
+}
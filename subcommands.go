@@ -0,0 +1,147 @@
+package basher
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// subcommandPrefix marks the functions that are subcommands. The rest of the
+// name is the command, with each dash separating a level of nesting, so
+// cmd-db-migrate is run by `app db migrate`.
+const subcommandPrefix = "cmd-"
+
+// functionDefinition matches the name in a line defining a Bash function,
+// as either "name()" or "function name".
+var functionDefinition = regexp.MustCompile(`(?m)^\s*(?:function\s+([^\s(){};&|<>]+)|([^\s(){};&|<>=]+)\s*\(\s*\))`)
+
+// subcommands lists the commands defined in the Context, sorted, as the words
+// used to run each one. Rather than running the scripts, it finds the
+// functions they define at the start of a line and adds the exported Go
+// functions, so it misses functions defined any other way.
+func subcommands(c *Context) [][]string {
+	c.Lock()
+	defer c.Unlock()
+	names := make(map[string]bool)
+	for _, script := range c.scripts {
+		for _, match := range functionDefinition.FindAllSubmatch(script, -1) {
+			names[string(match[1])+string(match[2])] = true
+		}
+	}
+	for name := range c.funcs {
+		names[name] = true
+	}
+	commands := make([][]string, 0)
+	for fn := range names {
+		name := strings.TrimPrefix(fn, subcommandPrefix)
+		if name == fn || name == "" {
+			continue
+		}
+		commands = append(commands, strings.Split(name, "-"))
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return strings.Join(commands[i], " ") < strings.Join(commands[j], " ")
+	})
+	return commands
+}
+
+// hasPrefix reports whether command starts with the words in prefix.
+func hasPrefix(command []string, prefix []string) bool {
+	if len(prefix) > len(command) {
+		return false
+	}
+	for i := range prefix {
+		if command[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dispatchFunc is the Bash function App runs for subcommands, with the
+// program name followed by its arguments. It is generated with the Short
+// descriptions of the commands in place of %s, so that the commands are
+// found and their help is written by the same Bash that runs them.
+const dispatchFunc = `__basher_dispatch() {
+	local program=$1 name prefix arg help= n i group=0
+	local -a words=() commands=()
+	local -A shorts=(%s)
+	shift
+	for arg in "$@"; do
+		[[ -n $arg && $arg != -* ]] || break
+		words+=("$arg")
+	done
+	for ((n = ${#words[@]}; n > 0; n--)); do
+		name=${words[0]}
+		for ((i = 1; i < n; i++)); do name+=-${words[i]}; done
+		if declare -F "cmd-$name" >/dev/null; then
+			shift "$n"
+			"cmd-$name" "$@"
+			return
+		fi
+	done
+
+	while read -r _ _ name; do
+		[[ $name == cmd-?* ]] && commands+=("${name#cmd-}")
+	done < <(declare -F)
+	if [[ ${words[0]-} == help ]]; then
+		help=1
+		words=("${words[@]:1}")
+	fi
+	# group is the longest run of words that some commands start with
+	for ((n = ${#words[@]}; n > 0 && group == 0; n--)); do
+		prefix=${words[0]}
+		for ((i = 1; i < n; i++)); do prefix+=-${words[i]}; done
+		for name in "${commands[@]}"; do
+			if [[ $name == "$prefix"-* ]]; then
+				group=$n
+				break
+			fi
+		done
+	done
+	if ((group < ${#words[@]})) || { [[ -z $help ]] && ((group < $#)); }; then
+		printf '%%s: unknown command: %%s\n' "$program" "$*" >&2
+		printf "Run '%%s help' for usage.\n" "$program" >&2
+		return 2
+	fi
+
+	prefix=
+	for arg in "${words[@]}"; do prefix+=$arg-; done
+	local -a names=()
+	local width=0
+	for name in "${commands[@]}"; do
+		[[ $name == "$prefix"?* ]] || continue
+		names+=("$name")
+		name=${name#"$prefix"}
+		((${#name} > width)) && width=${#name}
+	done
+	printf 'Usage: %%s <command> [args...]\n\nCommands:\n' "$program${words[*]:+ ${words[*]}}"
+	for name in "${names[@]}"; do
+		arg=${name#"$prefix"}
+		if [[ -n ${shorts[cmd-$name]-} ]]; then
+			printf '  %%-*s  %%s\n' "$width" "${arg//-/ }" "${shorts[cmd-$name]}"
+		else
+			printf '  %%s\n' "${arg//-/ }"
+		fi
+	done
+}
+`
+
+// writeDispatchFunc writes dispatchFunc with the Short descriptions of the
+// commands in specs.
+func writeDispatchFunc(w io.Writer, specs map[string]Spec) {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	shorts := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, subcommandPrefix) && specs[name].Short != "" {
+			shorts = append(shorts, "["+shellQuote(name)+"]="+shellQuote(specs[name].Short))
+		}
+	}
+	fmt.Fprintf(w, dispatchFunc, strings.Join(shorts, " "))
+}
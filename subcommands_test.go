@@ -0,0 +1,111 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runSubcommand(t *testing.T, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	app := NewApp(AppConfig{
+		Funcs: map[string]func([]string){
+			"cmd-version": func([]string) {},
+		},
+		Specs: map[string]Spec{
+			"cmd-version": {Short: "Print the version"},
+		},
+		Scripts:     []string{"cli.sh"},
+		Loader:      testLoader,
+		Subcommands: true,
+		BashPath:    bashpath,
+		Args:        append([]string{"/usr/bin/cli"}, args...),
+		Stdout:      &stdout,
+		Stderr:      &stderr,
+	})
+	status, err := app.Main(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, stdout.String(), stderr.String()
+}
+
+func TestSubcommandDispatch(t *testing.T) {
+	status, out, _ := runSubcommand(t, "deploy", "prod", "--force")
+	if status != 0 || out != "deploying prod --force\n" {
+		t.Fatalf("unexpected result: %d %q", status, out)
+	}
+	status, out, _ = runSubcommand(t, "db", "migrate", "up")
+	if status != 0 || out != "migrating up\n" {
+		t.Fatalf("unexpected result: %d %q", status, out)
+	}
+	status, _, _ = runSubcommand(t, "db", "seed")
+	if status != 3 {
+		t.Fatalf("unexpected status: %d", status)
+	}
+}
+
+func TestSubcommandHelp(t *testing.T) {
+	expected := "Usage: cli <command> [args...]\n\nCommands:\n" +
+		"  db migrate\n  db seed\n  deploy\n  version     Print the version\n"
+	for _, args := range [][]string{nil, {"help"}} {
+		status, out, _ := runSubcommand(t, args...)
+		if status != 0 || out != expected {
+			t.Fatalf("unexpected help for %q: %d %q", args, status, out)
+		}
+	}
+
+	expected = "Usage: cli db <command> [args...]\n\nCommands:\n  migrate\n  seed\n"
+	for _, args := range [][]string{{"db"}, {"help", "db"}} {
+		status, out, _ := runSubcommand(t, args...)
+		if status != 0 || out != expected {
+			t.Fatalf("unexpected help for %q: %d %q", args, status, out)
+		}
+	}
+}
+
+func TestSubcommandUnknown(t *testing.T) {
+	for _, args := range [][]string{{"helper"}, {"db", "drop"}, {"help", "nope"}} {
+		status, out, errOut := runSubcommand(t, args...)
+		if status != 2 || out != "" {
+			t.Fatalf("unexpected result for %q: %d %q", args, status, out)
+		}
+		if !strings.HasPrefix(errOut, "cli: unknown command: "+strings.Join(args, " ")+"\n") {
+			t.Fatalf("unexpected stderr for %q: %q", args, errOut)
+		}
+	}
+}
+
+func TestSubcommandSourcesOnce(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "count")
+	t.Setenv("COUNT_FILE", file)
+	count := func(subcommands bool, args ...string) int {
+		os.Remove(file)
+		app := NewApp(AppConfig{
+			Scripts:     []string{"counted.sh", "cli.sh"},
+			Loader:      testLoader,
+			Subcommands: subcommands,
+			CopyEnv:     true,
+			BashPath:    bashpath,
+			Args:        append([]string{"cli"}, args...),
+			Stdout:      io.Discard,
+			Stderr:      io.Discard,
+		})
+		if _, err := app.Main(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(file)
+		return strings.Count(string(data), "x")
+	}
+	// as many times as running main
+	want := count(false)
+	for _, args := range [][]string{{"help"}, {"deploy"}, {"nope"}} {
+		if got := count(true, args...); got != want {
+			t.Fatalf("scripts ran %d times for %q, want %d", got, args, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package basher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A bashWrap holds the Go hooks run around a Bash function.
+type bashWrap struct {
+	name   string
+	before func(args []string)
+	after  func(args []string, status int)
+}
+
+// A Wrap holds the hooks for WrapBashFunc, for AppConfig.Wraps.
+type Wrap struct {
+	Before func(args []string)
+	After  func(args []string, status int)
+}
+
+// WrapBashFunc runs Go code around the Bash function name, which is defined
+// by a sourced script, without changing the script. Once the scripts are
+// sourced, the function is renamed and replaced by a wrapper that calls back
+// into before with the arguments, runs the original, then calls back into
+// after with the arguments and its exit status, which it then returns. Either
+// hook can be nil. As with ExportFunc, the hooks run in a new process of the
+// executable, and if before exits with a non-zero status the function is not
+// run and returns that status. Nothing is wrapped if the function is not
+// defined.
+func (c *Context) WrapBashFunc(name string, before func(args []string), after func(args []string, status int)) {
+	c.Lock()
+	defer c.Unlock()
+	for i, wrap := range c.wraps {
+		if wrap.name == name {
+			c.wraps[i] = bashWrap{name, before, after}
+			return
+		}
+	}
+	c.wraps = append(c.wraps, bashWrap{name, before, after})
+}
+
+func beforeHook(name string) string  { return "__basher_before_" + name }
+func afterHook(name string) string   { return "__basher_after_" + name }
+func wrappedFunc(name string) string { return "__basher_wrapped_" + name }
+
+// hook returns the Go function for a hook callback with args. An after hook
+// must be given the exit status of the function before its arguments, or an
+// error wrapping ErrInvalidCallback is returned. The caller must hold the
+// lock.
+func (c *Context) hook(name string, args []string) (Handler, bool, error) {
+	for _, wrap := range c.wraps {
+		wrap := wrap
+		switch {
+		case wrap.before != nil && name == beforeHook(wrap.name):
+			return argsHandler(wrap.before), true, nil
+		case wrap.after != nil && name == afterHook(wrap.name):
+			if len(args) == 0 {
+				return nil, true, fmt.Errorf("%w: %s called without an exit status", ErrInvalidCallback, name)
+			}
+			status, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, true, fmt.Errorf("%w: %s called with exit status %q", ErrInvalidCallback, name, args[0])
+			}
+			return argsHandler(func(args []string) {
+				wrap.after(args[1:], status)
+			}), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// wrapCode returns the Bash code installing the wrappers, which is written to
+// the envfile after the scripts. The caller must hold the lock.
+func (c *Context) wrapCode() string {
+	var b strings.Builder
+	for _, wrap := range c.wraps {
+		name := wrap.name
+		fmt.Fprintf(&b, "if declare -F %s >/dev/null; then\n", shellQuote(name))
+		fmt.Fprintf(&b, "__basher_def=$(declare -f %s); eval \"%s${__basher_def#%s}\"; unset __basher_def\n",
+			shellQuote(name), wrappedFunc(name), name)
+		body := ""
+		if wrap.before != nil {
+			fmt.Fprintf(&b, "%s() { %s; }\n", beforeHook(name), c.callback(beforeHook(name), 2))
+			body += beforeHook(name) + " \"$@\" || return; "
+		}
+		body += wrappedFunc(name) + " \"$@\"; "
+		if wrap.after != nil {
+			fmt.Fprintf(&b, "%s() { %s; }\n", afterHook(name), c.callback(afterHook(name), 2))
+			body += "local __basher_status=$?; " + afterHook(name) + " \"$__basher_status\" \"$@\"; return $__basher_status; "
+		}
+		fmt.Fprintf(&b, "%s() { %s}\n", name, body)
+		b.WriteString("fi\n")
+	}
+	return b.String()
+}
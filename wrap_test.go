@@ -0,0 +1,116 @@
+package basher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWrapBashFunc(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("wrap.sh", testLoader)
+	bash.WrapBashFunc("deploy", func([]string) {}, func([]string, int) {})
+	bash.WrapBashFunc("build", nil, func([]string, int) {})
+	bash.WrapBashFunc("missing", func([]string) {}, nil)
+	// echo stands in for the executable, showing each callback
+	bash.SelfPath = "/bin/echo"
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	status, err := bash.Run("deploy", []string{"prod", "now"})
+	if status != 3 || err == nil {
+		t.Fatalf("unexpected result: %d, %v", status, err)
+	}
+	expected := bash.nonce + " __basher_before_deploy prod now\n" +
+		"deploying prod now\n" +
+		bash.nonce + " __basher_after_deploy 3 prod now\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if _, err := bash.Run("build", nil); err != nil {
+		t.Fatal(err)
+	}
+	expected = "building\n" + bash.nonce + " __basher_after_build 0\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestWrapBashFuncBeforeFails(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("wrap.sh", testLoader)
+	bash.WrapBashFunc("build", func([]string) {}, func([]string, int) {})
+	bash.SelfPath = "/bin/false"
+	var stdout bytes.Buffer
+	bash.Stdout = &stdout
+
+	status, _ := bash.Run("build", nil)
+	if status != 1 || stdout.String() != "" {
+		t.Fatalf("expected build not to run: %d %q", status, stdout.String())
+	}
+}
+
+func TestWrapBashFuncHooks(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	var trace []string
+	bash.WrapBashFunc("deploy", func(args []string) {
+		trace = append(trace, "before "+strings.Join(args, " "))
+	}, func(args []string, status int) {
+		trace = append(trace, "after "+strings.Join(args, " ")+" "+strconv.Itoa(status))
+	})
+
+	for _, args := range [][]string{
+		{"app", "nonce", "__basher_before_deploy", "prod"},
+		{"app", "nonce", "__basher_after_deploy", "3", "prod"},
+	} {
+		t.Setenv(callbackVar, "nonce")
+		if handled, err := bash.HandleCallback(args); !handled || err != nil {
+			t.Fatalf("unexpected result for %q: %v, %v", args, handled, err)
+		}
+	}
+	if got := strings.Join(trace, "|"); got != "before prod|after prod 3" {
+		t.Fatalf("unexpected trace: %q", got)
+	}
+}
+
+func TestWrapBashFuncAfterHookStatus(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.WrapBashFunc("deploy", nil, func([]string, int) {
+		t.Fatal("unexpected after hook")
+	})
+
+	for _, args := range [][]string{
+		{"app", "nonce", "__basher_after_deploy"},
+		{"app", "nonce", "__basher_after_deploy", "x", "prod"},
+	} {
+		t.Setenv(callbackVar, "nonce")
+		handled, err := bash.HandleCallback(args)
+		if !handled || !errors.Is(err, ErrInvalidCallback) {
+			t.Fatalf("unexpected result for %q: %v, %v", args, handled, err)
+		}
+	}
+}
+
+func TestWrapBashFuncFunctions(t *testing.T) {
+	bash, _ := NewContext(bashpath, false)
+	bash.Source("wrap.sh", testLoader)
+	bash.WrapBashFunc("deploy", func([]string) {}, nil)
+
+	funcs, err := bash.Functions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range funcs {
+		if strings.HasPrefix(fn.Name, "__basher_") {
+			t.Fatalf("unexpected internal function: %s", fn.Name)
+		}
+		if fn.Name == "deploy" && (fn.Source != "wrap.sh" || fn.Line != 2) {
+			t.Fatalf("unexpected location for deploy: %+v", fn)
+		}
+	}
+}